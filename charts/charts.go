@@ -0,0 +1,237 @@
+package charts
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables holding registry credentials for chart publishing
+const (
+	registryUsernameEnv = "TROYOPS_REGISTRY_USERNAME"
+	registryPasswordEnv = "TROYOPS_REGISTRY_PASSWORD"
+)
+
+// semverPattern matches MAJOR.MINOR.PATCH with optional pre-release and build metadata
+var semverPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// chartVersionLine matches the top-level version field in Chart.yaml
+var chartVersionLine = regexp.MustCompile(`(?m)^version:.*$`)
+
+// ChartCmd defines the command for managing Helm charts
+func ChartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chart",
+		Short: "Manage Helm charts",
+		Long:  `Package and publish the Helm charts maintained in this repository.`,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(publishCmd())
+
+	return cmd
+}
+
+// publishCmd creates a command to version, package and push a chart to an OCI registry
+func publishCmd() *cobra.Command {
+	var chartPath string
+	var bump string
+	var version string
+	var registry string
+	var plainHTTP bool
+
+	cmd := &cobra.Command{
+		Use:   "publish",
+		Short: "Bump, package and push a chart to an OCI registry",
+		Long: `Bump the chart version in Chart.yaml, package the chart and push it to an OCI registry.
+
+Credentials are read from the TROYOPS_REGISTRY_USERNAME and TROYOPS_REGISTRY_PASSWORD
+environment variables. When they are not set, the Docker config (DOCKER_CONFIG or
+~/.docker/config.json) is used instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			publishChart(chartPath, bump, version, registry, plainHTTP)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&chartPath, "chart", "c", filepath.Join("charts", "troyops-helm-chart"), "Path to the chart directory")
+	cmd.Flags().StringVarP(&bump, "bump", "b", "patch", "Version component to bump (patch, minor, major)")
+	cmd.Flags().StringVarP(&version, "version", "v", "", "Explicit chart version (overrides --bump)")
+	cmd.Flags().StringVarP(&registry, "registry", "r", "", "OCI registry to push to, e.g. oci://ghcr.io/org/charts (required)")
+	cmd.Flags().BoolVar(&plainHTTP, "plain-http", false, "Use plain HTTP for the registry (e.g. a local registry:2)")
+	cmd.MarkFlagRequired("registry")
+
+	return cmd
+}
+
+// publishChart bumps the chart version, packages the chart and pushes it to the registry
+func publishChart(chartPath, bump, version, registry string, plainHTTP bool) {
+	// Check if helm is installed
+	_, err := exec.LookPath("helm")
+	if err != nil {
+		fmt.Println("Error: Helm is not installed. Please install it first.")
+		fmt.Println("Installation instructions: https://helm.sh/docs/intro/install/")
+		return
+	}
+
+	if !strings.HasPrefix(registry, "oci://") {
+		fmt.Printf("Error: Registry must be an OCI reference starting with oci://, got: %s\n", registry)
+		return
+	}
+
+	chartFile := filepath.Join(chartPath, "Chart.yaml")
+	content, err := os.ReadFile(chartFile)
+	if err != nil {
+		fmt.Println("Error reading Chart.yaml:", err)
+		return
+	}
+
+	var chart struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(content, &chart); err != nil {
+		fmt.Println("Error parsing Chart.yaml:", err)
+		return
+	}
+
+	// Determine the new version
+	newVersion := version
+	if newVersion == "" {
+		newVersion, err = bumpVersion(chart.Version, bump)
+		if err != nil {
+			fmt.Println("Error bumping chart version:", err)
+			return
+		}
+	} else if !semverPattern.MatchString(newVersion) {
+		fmt.Printf("Error: Version '%s' is not a valid semantic version\n", newVersion)
+		return
+	}
+
+	fmt.Printf("Publishing %s chart version %s (previously %s)...\n", chart.Name, newVersion, chart.Version)
+
+	// Package the chart into a temporary directory
+	packageDir, err := os.MkdirTemp("", "troyops-chart-")
+	if err != nil {
+		fmt.Println("Error creating package directory:", err)
+		return
+	}
+	defer os.RemoveAll(packageDir)
+
+	fmt.Println("Packaging chart...")
+	packageCmd := exec.Command("helm", "package", chartPath, "--version", newVersion, "--destination", packageDir)
+	packageCmd.Stdout = os.Stdout
+	packageCmd.Stderr = os.Stderr
+	if err := packageCmd.Run(); err != nil {
+		fmt.Println("Error packaging chart:", err)
+		return
+	}
+	archive := filepath.Join(packageDir, fmt.Sprintf("%s-%s.tgz", chart.Name, newVersion))
+
+	// Resolve registry credentials
+	registryConfig, err := registryLogin(registry, plainHTTP)
+	if err != nil {
+		fmt.Println("Error logging in to registry:", err)
+		return
+	}
+
+	fmt.Printf("Pushing %s to %s...\n", filepath.Base(archive), registry)
+	pushArgs := []string{"push", archive, registry}
+	if registryConfig != "" {
+		pushArgs = append(pushArgs, "--registry-config", registryConfig)
+	}
+	if plainHTTP {
+		pushArgs = append(pushArgs, "--plain-http")
+	}
+	pushCmd := exec.Command("helm", pushArgs...)
+	pushCmd.Stdout = os.Stdout
+	pushCmd.Stderr = os.Stderr
+	if err := pushCmd.Run(); err != nil {
+		fmt.Println("Error pushing chart:", err)
+		return
+	}
+
+	// Record the published version only once the push has succeeded
+	updated := chartVersionLine.ReplaceAll(content, []byte("version: "+newVersion))
+	if err := os.WriteFile(chartFile, updated, 0644); err != nil {
+		fmt.Println("Error writing Chart.yaml:", err)
+		return
+	}
+
+	fmt.Printf("Chart %s:%s published successfully!\n", chart.Name, newVersion)
+}
+
+// bumpVersion increments the given component of a semantic version.
+// Pre-release and build metadata are dropped from the result.
+func bumpVersion(current, component string) (string, error) {
+	parts := semverPattern.FindStringSubmatch(current)
+	if parts == nil {
+		return "", fmt.Errorf("current version '%s' is not a valid semantic version", current)
+	}
+
+	major, _ := strconv.Atoi(parts[1])
+	minor, _ := strconv.Atoi(parts[2])
+	patch, _ := strconv.Atoi(parts[3])
+
+	switch component {
+	case "major":
+		major, minor, patch = major+1, 0, 0
+	case "minor":
+		minor, patch = minor+1, 0
+	case "patch":
+		// A pre-release of X.Y.Z is released as X.Y.Z itself
+		if parts[4] == "" {
+			patch++
+		}
+	default:
+		return "", fmt.Errorf("unsupported version component: %s", component)
+	}
+
+	return fmt.Sprintf("%d.%d.%d", major, minor, patch), nil
+}
+
+// registryLogin authenticates helm against the registry using environment credentials,
+// or returns the Docker config path to use as the registry config when they are absent
+func registryLogin(registry string, plainHTTP bool) (string, error) {
+	username := os.Getenv(registryUsernameEnv)
+	password := os.Getenv(registryPasswordEnv)
+
+	if username != "" && password != "" {
+		host := strings.SplitN(strings.TrimPrefix(registry, "oci://"), "/", 2)[0]
+		fmt.Printf("Logging in to %s as %s...\n", host, username)
+
+		loginArgs := []string{"registry", "login", host, "--username", username, "--password-stdin"}
+		if plainHTTP {
+			loginArgs = append(loginArgs, "--insecure")
+		}
+		loginCmd := exec.Command("helm", loginArgs...)
+		loginCmd.Stdin = strings.NewReader(password)
+		loginCmd.Stdout = os.Stdout
+		loginCmd.Stderr = os.Stderr
+		return "", loginCmd.Run()
+	}
+
+	// Fall back to the Docker config, which uses the same format as helm's registry config
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		configDir = filepath.Join(home, ".docker")
+	}
+	dockerConfig := filepath.Join(configDir, "config.json")
+	if _, err := os.Stat(dockerConfig); err != nil {
+		return "", nil
+	}
+
+	fmt.Printf("Using registry credentials from %s\n", dockerConfig)
+	return dockerConfig, nil
+}
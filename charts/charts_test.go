@@ -0,0 +1,31 @@
+package charts
+
+import "testing"
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		current, component, want string
+		wantErr                  bool
+	}{
+		{"1.2.3", "patch", "1.2.4", false},
+		{"1.2.3", "minor", "1.3.0", false},
+		{"1.2.3", "major", "2.0.0", false},
+		{"1.2.3-rc.1", "patch", "1.2.3", false},
+		{"1.2.3-rc.1", "minor", "1.3.0", false},
+		{"1.2.3+build.5", "patch", "1.2.4", false},
+		{"0.9.9", "minor", "0.10.0", false},
+		{"1.2", "patch", "", true},
+		{"v1.2.3", "patch", "", true},
+		{"1.2.3", "build", "", true},
+	}
+	for _, tt := range tests {
+		got, err := bumpVersion(tt.current, tt.component)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("bumpVersion(%q, %q) error = %v, wantErr %v", tt.current, tt.component, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("bumpVersion(%q, %q) = %q, want %q", tt.current, tt.component, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+// Render runs helm template for the chart and returns the decoded objects
+func Render(chartPath, release, namespace string, valuesFiles []string) ([]manifest.Object, error) {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return nil, fmt.Errorf("helm is not installed")
+	}
+
+	args := []string{"template", release, chartPath}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	for _, values := range valuesFiles {
+		args = append(args, "--values", values)
+	}
+
+	var stdout, stderr bytes.Buffer
+	templateCmd := exec.Command("helm", args...)
+	templateCmd.Stdout = &stdout
+	templateCmd.Stderr = &stderr
+	if err := templateCmd.Run(); err != nil {
+		return nil, fmt.Errorf("helm template failed: %v: %s", err, stderr.String())
+	}
+
+	return manifest.Decode(stdout.Bytes())
+}
@@ -2,9 +2,10 @@ package ci
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/jefftrojan/troyops/gitops"
 	"github.com/spf13/cobra"
 )
 
@@ -13,168 +14,142 @@ func SetupCICDCmd() *cobra.Command {
 	var platform string
 	var repoPath string
 	var appName string
+	var registry RegistryOptions
+	var tagStrategy string
+	var gitOps GitOpsOptions
+	var preview PreviewOptions
+	var troyopsRef string
+	var mode WriteMode
 
 	cmd := &cobra.Command{
 		Use:   "cicd",
-		Short: "Setup CI/CD pipeline (GitHub Actions/GitLab CI)",
-		Long:  `Configure CI/CD pipelines using GitHub Actions or GitLab CI.`,
+		Short: "Setup CI/CD pipeline (GitHub Actions, GitLab CI, Jenkins, Azure, Bitbucket, CircleCI, Tekton)",
+		Long: `Configure CI/CD pipelines that build and push the application image and deploy it to
+the environments of troyops.yaml, on any of the registered platforms.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			setupCICD(platform, repoPath, appName)
+			setupCICD(platform, repoPath, appName, registry, tagStrategy, gitOps, preview, troyopsRef, mode)
 		},
 	}
 
 	// Add flags
-	cmd.Flags().StringVarP(&platform, "platform", "p", "github", "CI/CD platform to use (github, gitlab)")
+	cmd.Flags().StringVarP(&platform, "platform", "p", "github", "CI/CD platform to use ("+strings.Join(Platforms(), ", ")+")")
 	cmd.Flags().StringVarP(&repoPath, "repo-path", "r", ".", "Path to the Git repository")
 	cmd.Flags().StringVarP(&appName, "app-name", "a", "app", "Name of the application")
+	cmd.Flags().StringVar(&registry.Name, "registry", "dockerhub", "Container registry to push to ("+strings.Join(Registries(), ", ")+"); ecr, acr, harbor and custom need --registry-host")
+	cmd.Flags().StringVar(&registry.Host, "registry-host", "", "Registry host, e.g. 123456789012.dkr.ecr.eu-west-1.amazonaws.com or myregistry.azurecr.io")
+	cmd.Flags().StringVar(&registry.Namespace, "registry-namespace", "", "Organization, project or path images are pushed under; required for gcr (the GCP project) and harbor")
+	cmd.Flags().StringVar(&tagStrategy, "tag-strategy", TagSHA, "Tag builds of the branch are deployed as: sha (sha-<short sha>), branch (<branch>-<short sha>-<unix time>) or semver (only release tags are deployed)")
+	cmd.Flags().StringVar(&gitOps.Mode, "gitops", GitOpsPush, "How deployment stages update overlays: push commits to the branch, pr opens a pull request per environment with troyops gitops pr")
+	cmd.Flags().StringArrayVar(&gitOps.Labels, "pr-label", nil, "Label added to deployment pull requests (repeatable)")
+	cmd.Flags().BoolVar(&gitOps.AutoMerge, "auto-merge", false, "Merge deployment pull requests once their checks pass")
+	cmd.Flags().StringVar(&gitOps.MergeMethod, "merge-method", "squash", "How deployment pull requests are merged ("+strings.Join(gitops.MergeMethods, ", ")+")")
+	cmd.Flags().BoolVar(&preview.Enabled, "preview", false, "Deploy each pull request to a pr-<number> namespace with troyops preview, removed on close (GitHub Actions and GitLab CI)")
+	cmd.Flags().StringVar(&preview.From, "preview-from", "", "Environment whose overlay previews are created from (default: the first environment)")
+	cmd.Flags().StringVar(&preview.Domain, "preview-domain", "", "Wildcard DNS domain preview hosts are moved under, e.g. preview.example.com")
+	cmd.Flags().DurationVar(&preview.TTL, "preview-ttl", 72*time.Hour, "How long previews are kept without updates")
+	cmd.Flags().StringVar(&troyopsRef, "troyops-ref", DefaultTroyopsRef(), "Commit SHA or release tag of troyops that pull request and preview jobs install (default: the commit this binary was built from)")
+	cmd.Flags().BoolVar(&mode.Force, "force", false, "Overwrite pipeline files even when they have local edits")
+	cmd.Flags().BoolVar(&mode.Merge, "merge", false, "Three-way merge local edits with the regenerated pipeline")
+	cmd.Flags().BoolVar(&mode.Diff, "diff", false, "Show the changes regeneration would make without writing files")
+	cmd.MarkFlagsMutuallyExclusive("force", "merge", "diff")
 
 	return cmd
 }
 
 // setupCICD configures the CI/CD pipeline based on the platform
-func setupCICD(platform, repoPath, appName string) {
-	fmt.Printf("Setting up CI/CD pipeline for %s on %s platform...\n", appName, platform)
-
-	switch platform {
-	case "github":
-		setupGitHubActions(repoPath, appName)
-	case "gitlab":
-		setupGitLabCI(repoPath, appName)
-	default:
-		fmt.Printf("Unsupported CI/CD platform: %s\n", platform)
+func setupCICD(platform, repoPath, appName string, registry RegistryOptions, tagStrategy string, gitOps GitOpsOptions, preview PreviewOptions, troyopsRef string, mode WriteMode) {
+	p, ok := platforms[platform]
+	if !ok {
+		fmt.Printf("Unsupported CI/CD platform: %s (available: %s)\n", platform, strings.Join(Platforms(), ", "))
+		return
 	}
-}
 
-// setupGitHubActions configures GitHub Actions workflows
-func setupGitHubActions(repoPath, appName string) {
-	fmt.Println("Creating GitHub Actions workflow...")
+	fmt.Printf("Setting up CI/CD pipeline for %s on %s platform...\n", appName, platform)
 
-	// Create .github/workflows directory if it doesn't exist
-	workflowsDir := filepath.Join(repoPath, ".github", "workflows")
-	if err := os.MkdirAll(workflowsDir, 0755); err != nil {
-		fmt.Println("Error creating workflows directory:", err)
+	data, err := newData(appName, p, registry, tagStrategy, gitOps, preview, troyopsRef)
+	if err != nil {
+		fmt.Println("Error:", err)
 		return
 	}
 
-	// Create main workflow file
-	workflowFile := filepath.Join(workflowsDir, fmt.Sprintf("%s-ci.yml", appName))
-	workflowContent := fmt.Sprintf(`name: %s CI/CD
-
-on:
-  push:
-    branches: [ main ]
-  pull_request:
-    branches: [ main ]
-
-jobs:
-  build:
-    runs-on: ubuntu-latest
-    steps:
-      - uses: actions/checkout@v3
-        with:
-          fetch-depth: 0
-
-      - name: Set up Docker Buildx
-        uses: docker/setup-buildx-action@v2
-
-      - name: Login to Docker Hub
-        uses: docker/login-action@v2
-        with:
-          username: ${{ secrets.DOCKER_HUB_USERNAME }}
-          password: ${{ secrets.DOCKER_HUB_TOKEN }}
-
-      - name: Build and push
-        uses: docker/build-push-action@v4
-        with:
-          context: .
-          push: ${{ github.event_name != 'pull_request' }}
-          tags: ${{ secrets.DOCKER_HUB_USERNAME }}/%s:latest
-
-  deploy:
-    needs: build
-    if: github.event_name != 'pull_request'
-    runs-on: ubuntu-latest
-    steps:
-      - uses: actions/checkout@v3
-        with:
-          fetch-depth: 0
-
-      - name: Setup Flux
-        uses: fluxcd/flux2/action@main
-
-      - name: Update Kubernetes manifests
-        run: |
-          cd ./kustomize/overlays/dev
-          kustomize edit set image ${{ secrets.DOCKER_HUB_USERNAME }}/%s:latest
-          git config --global user.name "Flux CD"
-          git config --global user.email "flux@example.com"
-          git add .
-          git commit -m "Update %s image tag"
-          git push
-`, appName, appName, appName, appName)
-
-	if err := os.WriteFile(workflowFile, []byte(workflowContent), 0644); err != nil {
-		fmt.Println("Error creating workflow file:", err)
+	if err := p.generate(repoPath, data, mode); err != nil {
+		fmt.Printf("Error generating %s pipeline: %v\n", p.Description, err)
+		return
+	}
+	if mode.Diff {
 		return
 	}
 
-	fmt.Printf("GitHub Actions workflow created at %s\n", workflowFile)
-	fmt.Println("Note: You need to set DOCKER_HUB_USERNAME and DOCKER_HUB_TOKEN secrets in your GitHub repository.")
+	printStages(p, data)
+	printChecklist(p, data)
 }
 
-// setupGitLabCI configures GitLab CI pipeline
-func setupGitLabCI(repoPath, appName string) {
-	fmt.Println("Creating GitLab CI pipeline...")
-
-	// Create .gitlab-ci.yml file
-	ciFile := filepath.Join(repoPath, ".gitlab-ci.yml")
-	ciContent := fmt.Sprintf(`stages:
-  - build
-  - deploy
-
-variables:
-  DOCKER_DRIVER: overlay2
-  DOCKER_TLS_CERTDIR: ""
-
-build:
-  stage: build
-  image: docker:20.10.16
-  services:
-    - docker:20.10.16-dind
-  before_script:
-    - docker login -u $DOCKER_HUB_USERNAME -p $DOCKER_HUB_TOKEN
-  script:
-    - docker build -t $DOCKER_HUB_USERNAME/%s:latest .
-    - docker push $DOCKER_HUB_USERNAME/%s:latest
-  only:
-    - main
+// printStages describes when each environment is deployed
+func printStages(p *Platform, data *Data) {
+	fmt.Println("\nDeployment stages:")
+	for _, env := range data.Environments {
+		when := "on builds of " + strings.Join(data.Branches, ", ")
+		if env.Trigger == PromoteTag {
+			when = "on release tags v*"
+		}
+		if env.Manual {
+			when += ", after approval: " + fmt.Sprintf(p.ApprovalHint, env.Name)
+		}
+		fmt.Printf("  %-12s %s %s\n", env.Name, env.Overlay, when)
+	}
+	if g := data.GitOps; g.PullRequests() {
+		fmt.Printf("Overlay changes are proposed as %s pull requests from troyops/deploy-<environment>", g.Provider)
+		if len(g.Labels) > 0 {
+			fmt.Printf(" labelled %s", strings.Join(g.Labels, ", "))
+		}
+		if g.AutoMerge {
+			fmt.Printf(", auto-merged (%s) once their checks pass", g.MergeMethod)
+		}
+		fmt.Println()
+	}
+	if pv := data.Preview; pv.Enabled {
+		fmt.Printf("Pull requests are deployed to pr-<number> namespaces from the %s overlay, removed on close or after %s without updates\n", pv.From, pv.TTL)
+	}
+}
 
-deploy:
-  stage: deploy
-  image: 
-    name: fluxcd/flux:latest
-    entrypoint: [""]
-  before_script:
-    - apt-get update && apt-get install -y git curl
-    - curl -s https://raw.githubusercontent.com/kubernetes-sigs/kustomize/master/hack/install_kustomize.sh | bash
-    - mv kustomize /usr/local/bin/
-  script:
-    - cd ./kustomize/overlays/dev
-    - kustomize edit set image $DOCKER_HUB_USERNAME/%s:latest
-    - git config --global user.name "Flux CD"
-    - git config --global user.email "flux@example.com"
-    - git add .
-    - git commit -m "Update %s image tag"
-    - git push
-  only:
-    - main
-`, appName, appName, appName, appName)
+// printChecklist lists the secrets the pipeline needs to push images
+func printChecklist(p *Platform, data *Data) {
+	secrets, builtin, notes := checklist(data.Registry, p.Name)
+	fmt.Printf("\nImages are pushed to %s with immutable tags, deploying the %s tag\n", data.Registry.image(data.Image, p.Secret), data.TagStrategy)
+	if builtin != "" {
+		fmt.Printf("No registry secrets to configure: %s logs in with %s.\n", p.Description, builtin)
+	} else {
+		fmt.Printf("Configure the following %s:\n", p.SecretsHint)
+		for _, secret := range secrets {
+			fmt.Printf("  [ ] %-24s %s\n", secret.Name, secret.Description)
+		}
+	}
+	for _, note := range notes {
+		fmt.Printf("Note: %s.\n", note)
+	}
 
-	if err := os.WriteFile(ciFile, []byte(ciContent), 0644); err != nil {
-		fmt.Println("Error creating GitLab CI file:", err)
-		return
+	token, builtinToken := data.GitOps.tokenChecklist(p.Name)
+	switch {
+	case token != nil:
+		fmt.Printf("Configure the token for deployment pull requests %s:\n  [ ] %-24s %s\n", p.TokenHint, token.Name, token.Description)
+	case builtinToken != "":
+		fmt.Printf("Deployment pull requests are opened with %s; allow GitHub Actions to create pull requests in the repository's Actions settings.\n", builtinToken)
+	}
+	if data.GitOps.AutoMerge && data.GitOps.Provider == gitops.ProviderGitHub {
+		fmt.Println("Note: allow auto-merge in the repository settings.")
+		if builtinToken != "" {
+			fmt.Println("Note: pull requests opened with the workflow's GITHUB_TOKEN do not trigger workflows, so required checks never run on them.")
+		}
 	}
 
-	fmt.Printf("GitLab CI pipeline created at %s\n", ciFile)
-	fmt.Println("Note: You need to set DOCKER_HUB_USERNAME and DOCKER_HUB_TOKEN variables in your GitLab CI/CD settings.")
+	previewSecrets, previewNotes := data.Preview.checklist(p.Name, data.GitOps)
+	if len(previewSecrets) > 0 {
+		fmt.Printf("Configure the following for preview environments %s:\n", p.SecretsHint)
+		for _, secret := range previewSecrets {
+			fmt.Printf("  [ ] %-24s %s\n", secret.Name, secret.Description)
+		}
+	}
+	for _, note := range previewNotes {
+		fmt.Printf("Note: %s.\n", note)
+	}
 }
@@ -0,0 +1,198 @@
+package ci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+)
+
+// BaseDir keeps the last generated version of every pipeline file, relative to the repository.
+// It is the common ancestor for three-way merges and should be committed.
+var BaseDir = filepath.Join(".troyops", "generated")
+
+// generatedHeader is the first line of every generated pipeline file
+const generatedHeader = "%s Generated by troyops from %s. Run 'troyops cicd --merge' to keep local edits on regeneration. checksum: sha256:%s\n"
+
+// checksumPattern extracts the checksum from a generated header
+var checksumPattern = regexp.MustCompile(`^\S+ Generated by troyops .* checksum: sha256:([0-9a-f]{64})\r?\n`)
+
+// WriteMode selects how existing pipeline files are treated
+type WriteMode struct {
+	Force bool
+	Merge bool
+	Diff  bool
+}
+
+// generatedFile is a pipeline file rendered from a template
+type generatedFile struct {
+	Path     string
+	Template string
+	Comment  string
+	Content  []byte
+}
+
+// withHeader returns the file content prefixed with the checksum header
+func (f *generatedFile) withHeader(body []byte) []byte {
+	header := fmt.Sprintf(generatedHeader, f.Comment, f.Template, checksum(f.Content))
+	return append([]byte(header), body...)
+}
+
+// write stores a generated file in the repository without losing local edits.
+// Unmodified files are replaced; modified ones are only replaced with Force,
+// merged with Merge or compared with Diff.
+func (f *generatedFile) write(repoPath string, mode WriteMode) error {
+	target := filepath.Join(repoPath, f.Path)
+	basePath := filepath.Join(repoPath, BaseDir, f.Path)
+	output := f.withHeader(f.Content)
+
+	current, err := os.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+
+	if mode.Diff {
+		if !exists {
+			current = nil
+		}
+		return showDiff(f.Path, current, output)
+	}
+
+	if exists && !mode.Force {
+		modified, known := isModified(current)
+		switch {
+		case !known && !mode.Merge:
+			return fmt.Errorf("%s was not generated by troyops; use --diff to compare, --merge to merge or --force to overwrite", target)
+		case modified && !mode.Merge:
+			return fmt.Errorf("%s has local edits; use --diff to compare, --merge to merge or --force to overwrite", target)
+		case modified || !known:
+			base, err := os.ReadFile(basePath)
+			if err != nil {
+				return fmt.Errorf("no previous generated version of %s in %s to merge with; use --force to overwrite", f.Path, BaseDir)
+			}
+			merged, conflicts, err := mergeFile(stripHeader(current), stripHeader(base), f.Content)
+			if err != nil {
+				return err
+			}
+			output = f.withHeader(merged)
+			if conflicts > 0 {
+				fmt.Printf("Warning: %d merge conflict(s) in %s, resolve the conflict markers before committing\n", conflicts, target)
+			} else {
+				fmt.Printf("Merged local edits of %s with the new template\n", target)
+			}
+		case bytes.Equal(current, output):
+			fmt.Printf("%s is up to date\n", target)
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, output, 0644); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(basePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(basePath, f.Content, 0644)
+}
+
+// isModified reports whether a generated file was edited since it was written.
+// known is false when the file has no troyops header.
+func isModified(content []byte) (modified, known bool) {
+	match := checksumPattern.FindSubmatch(content)
+	if match == nil {
+		return false, false
+	}
+	return checksum(stripHeader(content)) != string(match[1]), true
+}
+
+// stripHeader removes the checksum header from file content
+func stripHeader(content []byte) []byte {
+	if loc := checksumPattern.FindIndex(content); loc != nil {
+		return content[loc[1]:]
+	}
+	return content
+}
+
+// checksum returns the hex SHA-256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// mergeFile performs a three-way merge with git merge-file and returns the result and the number of conflicts
+func mergeFile(current, base, next []byte) ([]byte, int, error) {
+	dir, err := os.MkdirTemp("", "troyops-merge-")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	paths := map[string][]byte{"local": current, "generated": base, "template": next}
+	for name, content := range paths {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	mergeCmd := exec.Command("git", "merge-file", "-p", "-L", "local edits", "-L", "previous template", "-L", "new template",
+		filepath.Join(dir, "local"), filepath.Join(dir, "generated"), filepath.Join(dir, "template"))
+	mergeCmd.Stdout = &stdout
+	mergeCmd.Stderr = &stderr
+	err = mergeCmd.Run()
+
+	// git merge-file exits with the number of conflicts
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return stdout.Bytes(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("git merge-file failed: %v: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), 0, nil
+}
+
+// showDiff prints a unified diff from the current file to the generated one
+func showDiff(path string, current, next []byte) error {
+	dir, err := os.MkdirTemp("", "troyops-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "current"), current, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "generated"), next, 0644); err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	diffCmd := exec.Command("git", "diff", "--no-index", "--", "current", "generated")
+	diffCmd.Dir = dir
+	diffCmd.Stdout = &stdout
+	err = diffCmd.Run()
+
+	// git diff exits with 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return fmt.Errorf("git diff failed: %v", err)
+	}
+	if stdout.Len() == 0 {
+		fmt.Printf("%s is up to date\n", path)
+		return nil
+	}
+	out := bytes.ReplaceAll(stdout.Bytes(), []byte("a/current"), []byte("a/"+path))
+	out = bytes.ReplaceAll(out, []byte("b/generated"), []byte("b/"+path))
+	fmt.Print(string(out))
+	return nil
+}
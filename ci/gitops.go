@@ -0,0 +1,179 @@
+package ci
+
+import (
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jefftrojan/troyops/gitops"
+)
+
+// GitOps modes select how deployment stages change the overlays
+const (
+	// GitOpsPush commits the overlay change straight to the deploy branch
+	GitOpsPush = "push"
+	// GitOpsPR opens a pull request with the overlay change through troyops gitops pr
+	GitOpsPR = "pr"
+)
+
+// GitOpsModes lists the supported GitOps modes
+var GitOpsModes = []string{GitOpsPush, GitOpsPR}
+
+// troyopsRepository is where pipeline steps fetch the troyops CLI from
+const troyopsRepository = "https://github.com/jefftrojan/troyops.git"
+
+// troyopsRefPattern matches the refs pipelines may install troyops from: a full commit SHA,
+// which git verifies the fetched tree against, or a release tag vX.Y.Z
+var troyopsRefPattern = regexp.MustCompile(`^([0-9a-f]{40}|v[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?)$`)
+
+// troyopsInstall returns the shell building the troyops CLI at ref in a pipeline step, like troyops.sh
+// does locally. Agents running several deployment stages, such as Jenkins, build it once.
+func troyopsInstall(ref string) string {
+	return fmt.Sprintf(`if [ ! -x /tmp/troyops/bin/troyops ]; then
+  rm -rf /tmp/troyops
+  git init -q /tmp/troyops
+  git -C /tmp/troyops fetch -q --depth 1 %s %s
+  git -C /tmp/troyops checkout -q FETCH_HEAD
+  (cd /tmp/troyops && go build -o bin/troyops cmd/troyops.go)
+fi`, troyopsRepository, ref)
+}
+
+// validTroyopsRef checks the ref pipelines install troyops from
+func validTroyopsRef(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("pipelines running troyops need --troyops-ref, the commit SHA or release tag to install")
+	}
+	if !troyopsRefPattern.MatchString(ref) {
+		return fmt.Errorf("--troyops-ref must be a full commit SHA or a release tag vX.Y.Z, got '%s'", ref)
+	}
+	return nil
+}
+
+// DefaultTroyopsRef returns the commit the running troyops binary was built from,
+// or an empty string when the build carries no clean VCS revision
+func DefaultTroyopsRef() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision":
+			revision = setting.Value
+		case setting.Key == "vcs.modified" && setting.Value == "true":
+			return ""
+		}
+	}
+	return revision
+}
+
+// GitOpsOptions selects how deployment stages change the overlays
+type GitOpsOptions struct {
+	Mode        string
+	Labels      []string
+	AutoMerge   bool
+	MergeMethod string
+}
+
+// GitOps describes how deployment stages change the overlays
+type GitOps struct {
+	Mode        string
+	Labels      []string
+	AutoMerge   bool
+	MergeMethod string
+	// Provider hosts the repository pull requests are opened on, gitops.ProviderGitHub or gitops.ProviderGitLab
+	Provider string
+	// TokenVariable is the environment variable troyops gitops pr reads the provider token from
+	TokenVariable string
+}
+
+// PullRequests reports whether deployment stages open pull requests
+func (g GitOps) PullRequests() bool {
+	return g.Mode == GitOpsPR
+}
+
+// newGitOps resolves the GitOps options for a platform. The pull request provider is the
+// platform itself for GitHub Actions and GitLab CI, and is derived from repoURL otherwise.
+func newGitOps(opts GitOpsOptions, p *Platform, repoURL string) (GitOps, error) {
+	switch opts.Mode {
+	case GitOpsPush:
+		return GitOps{Mode: GitOpsPush}, nil
+	case GitOpsPR:
+	default:
+		return GitOps{}, fmt.Errorf("unsupported GitOps mode: %s (available: %s)", opts.Mode, strings.Join(GitOpsModes, ", "))
+	}
+
+	if !p.PullRequests {
+		return GitOps{}, fmt.Errorf("%s cannot open pull requests, use --gitops %s", p.Description, GitOpsPush)
+	}
+	if !validMergeMethod(opts.MergeMethod) {
+		return GitOps{}, fmt.Errorf("unsupported merge method: %s (available: %s)", opts.MergeMethod, strings.Join(gitops.MergeMethods, ", "))
+	}
+
+	g := GitOps{Mode: GitOpsPR, Labels: opts.Labels, AutoMerge: opts.AutoMerge, MergeMethod: opts.MergeMethod}
+	g.Provider = p.Name
+	if g.Provider != gitops.ProviderGitHub && g.Provider != gitops.ProviderGitLab {
+		switch {
+		case strings.Contains(repoURL, "github"):
+			g.Provider = gitops.ProviderGitHub
+		case strings.Contains(repoURL, "gitlab"):
+			g.Provider = gitops.ProviderGitLab
+		default:
+			return GitOps{}, fmt.Errorf("pull requests need the repository on GitHub or GitLab, but repository.url in troyops.yaml is '%s'", repoURL)
+		}
+	}
+	g.TokenVariable = "GITHUB_TOKEN"
+	if g.Provider == gitops.ProviderGitLab {
+		g.TokenVariable = "GITLAB_TOKEN"
+	}
+	return g, nil
+}
+
+// validMergeMethod reports whether method is supported by troyops gitops pr
+func validMergeMethod(method string) bool {
+	for _, m := range gitops.MergeMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// pullRequestScript returns the troyops command opening the pull request deploying image@digest.
+// environment, overlay, tag and digest are values or expressions in the platform's syntax; base is the deploy branch.
+func (g GitOps) pullRequestScript(app, image, environment, overlay, tag, digest, base string) string {
+	args := []string{
+		"/tmp/troyops/bin/troyops", "gitops", "pr",
+		"--environment", environment,
+		"--overlay", overlay,
+		"--image", image + "@" + digest,
+		"--tag", tag,
+		"--title", fmt.Sprintf(`"Deploy %s %s to %s"`, app, tag, environment),
+		"--base", base,
+		"--provider", g.Provider,
+	}
+	for _, label := range g.Labels {
+		args = append(args, "--label", `"`+label+`"`)
+	}
+	if g.AutoMerge {
+		args = append(args, "--auto-merge", "--merge-method", g.MergeMethod)
+	}
+	return strings.Join(args, " ")
+}
+
+// tokenChecklist returns the secret holding the provider token, or the source of the platform's built-in token
+func (g GitOps) tokenChecklist(platform string) (*requiredSecret, string) {
+	if !g.PullRequests() {
+		return nil, ""
+	}
+	if platform == "github" {
+		return nil, "the workflow's GITHUB_TOKEN"
+	}
+	description := "GitHub token with the repo scope, used to push the deploy branch and open pull requests"
+	if g.Provider == gitops.ProviderGitLab {
+		description = "GitLab project access token with the api and write_repository scopes, used to push the deploy branch and open merge requests"
+	}
+	return &requiredSecret{g.TokenVariable, description}, ""
+}
@@ -0,0 +1,94 @@
+package ci
+
+import "testing"
+
+func TestNewGitOps(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     GitOpsOptions
+		platform string
+		repoURL  string
+		provider string
+		token    string
+		wantErr  bool
+	}{
+		{
+			name:     "push needs no provider",
+			opts:     GitOpsOptions{Mode: GitOpsPush},
+			platform: "bitbucket",
+		},
+		{
+			name:     "github actions opens pull requests on github",
+			opts:     GitOpsOptions{Mode: GitOpsPR, MergeMethod: "squash"},
+			platform: "github",
+			repoURL:  "https://gitlab.com/acme/gitops.git",
+			provider: "github",
+			token:    "GITHUB_TOKEN",
+		},
+		{
+			name:     "other platforms follow the repository url",
+			opts:     GitOpsOptions{Mode: GitOpsPR, MergeMethod: "merge"},
+			platform: "jenkins",
+			repoURL:  "git@gitlab.example.com:acme/gitops.git",
+			provider: "gitlab",
+			token:    "GITLAB_TOKEN",
+		},
+		{
+			name:     "unknown repository host",
+			opts:     GitOpsOptions{Mode: GitOpsPR, MergeMethod: "squash"},
+			platform: "circleci",
+			repoURL:  "https://git.example.com/acme/gitops.git",
+			wantErr:  true,
+		},
+		{
+			name:     "platform without pull requests",
+			opts:     GitOpsOptions{Mode: GitOpsPR, MergeMethod: "squash"},
+			platform: "bitbucket",
+			repoURL:  "https://github.com/acme/gitops.git",
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported merge method",
+			opts:     GitOpsOptions{Mode: GitOpsPR, MergeMethod: "fast-forward"},
+			platform: "github",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := newGitOps(tt.opts, platforms[tt.platform], tt.repoURL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Provider != tt.provider || g.TokenVariable != tt.token {
+				t.Errorf("got provider %q with token %q, want %q with %q", g.Provider, g.TokenVariable, tt.provider, tt.token)
+			}
+		})
+	}
+}
+
+func TestValidTroyopsRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		wantErr bool
+	}{
+		{"0123456789abcdef0123456789abcdef01234567", false},
+		{"v1.4.0", false},
+		{"v2.0.0-rc.1", false},
+		{"", true},
+		{"main", true},
+		{"0123456", true},
+	}
+	for _, tt := range tests {
+		if err := validTroyopsRef(tt.ref); (err != nil) != tt.wantErr {
+			t.Errorf("validTroyopsRef(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ci
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Platform generates the pipeline files of a CI/CD system
+type Platform struct {
+	Name        string
+	Description string
+	Files       []PipelineFile
+	Secret      func(name string) string
+	SecretsHint string
+	// ApprovalHint explains how a manual deployment to the environment %s is approved
+	ApprovalHint string
+	// PullRequests is set when deployment stages can open pull requests instead of pushing
+	PullRequests bool
+	// TokenHint explains where the token for deployment pull requests is configured
+	TokenHint string
+	// Previews is set when pipelines can deploy preview environments of pull requests
+	Previews bool
+}
+
+// PipelineFile is a file of a platform rendered from a template
+type PipelineFile struct {
+	Template string
+	Comment  string
+	Path     func(data *Data) string
+	// Enabled reports whether the file is generated for the data; nil means always
+	Enabled func(data *Data) bool
+}
+
+// platforms holds the registered CI/CD platforms by name
+var platforms = map[string]*Platform{}
+
+// Register makes a CI/CD platform available to the cicd command
+func Register(p *Platform) {
+	if _, exists := platforms[p.Name]; exists {
+		panic(fmt.Sprintf("ci: platform %s registered twice", p.Name))
+	}
+	platforms[p.Name] = p
+}
+
+// Platforms returns the names of the registered platforms in order
+func Platforms() []string {
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generate renders the files of the platform and writes them to the repository
+func (p *Platform) generate(repoPath string, data *Data, mode WriteMode) error {
+	for _, file := range p.Files {
+		if file.Enabled != nil && !file.Enabled(data) {
+			continue
+		}
+		content, err := render(repoPath, file.Template, p.Secret, data)
+		if err != nil {
+			return err
+		}
+
+		generated := generatedFile{
+			Path:     file.Path(data),
+			Template: file.Template,
+			Comment:  file.Comment,
+			Content:  content,
+		}
+		if err := generated.write(repoPath, mode); err != nil {
+			return err
+		}
+		if !mode.Diff {
+			fmt.Printf("%s pipeline written to %s\n", p.Description, generated.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ci
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// envVariable references a secret exposed to pipeline scripts as an environment variable
+func envVariable(name string) string {
+	return "$" + name
+}
+
+// macroVariable references an Azure Pipelines or Tekton style $(NAME) variable
+func macroVariable(name string) string {
+	return "$(" + name + ")"
+}
+
+// fixedPath returns a path function for a file that does not depend on the data
+func fixedPath(path string) func(*Data) string {
+	return func(*Data) string { return path }
+}
+
+func init() {
+	Register(&Platform{
+		Name:        "github",
+		Description: "GitHub Actions",
+		Files: []PipelineFile{{
+			Template: "github-actions.yml.tmpl",
+			Comment:  "#",
+			Path: func(data *Data) string {
+				return filepath.Join(".github", "workflows", fmt.Sprintf("%s-ci.yml", data.App))
+			},
+		}, {
+			Template: "github-preview.yml.tmpl",
+			Comment:  "#",
+			Path: func(data *Data) string {
+				return filepath.Join(".github", "workflows", fmt.Sprintf("%s-preview.yml", data.App))
+			},
+			Enabled: func(data *Data) bool { return data.Preview.Enabled },
+		}},
+		Secret:       githubSecret,
+		SecretsHint:  "as secrets in your GitHub repository",
+		ApprovalHint: "add required reviewers to the %s environment in the repository settings",
+		PullRequests: true,
+		Previews:     true,
+	})
+
+	Register(&Platform{
+		Name:        "gitlab",
+		Description: "GitLab CI",
+		Files: []PipelineFile{{
+			Template: "gitlab-ci.yml.tmpl",
+			Comment:  "#",
+			Path:     fixedPath(".gitlab-ci.yml"),
+		}},
+		Secret:       gitlabVariable,
+		SecretsHint:  "as variables in your GitLab CI/CD settings",
+		ApprovalHint: "run the manual deploy-%s job from the pipeline page",
+		PullRequests: true,
+		TokenHint:    "as a masked variable in your GitLab CI/CD settings",
+		Previews:     true,
+	})
+
+	Register(&Platform{
+		Name:        "jenkins",
+		Description: "Jenkins",
+		Files: []PipelineFile{{
+			Template: "Jenkinsfile.tmpl",
+			Comment:  "//",
+			Path:     fixedPath("Jenkinsfile"),
+		}},
+		Secret:       envVariable,
+		SecretsHint:  "as the username and password of a Jenkins credential with ID 'registry-credentials', and give the job push access to the repository",
+		ApprovalHint: "answer the input of the 'Deploy to %s' stage",
+		PullRequests: true,
+		TokenHint:    "as a secret text Jenkins credential with ID 'gitops-token'",
+	})
+
+	Register(&Platform{
+		Name:        "azure",
+		Description: "Azure Pipelines",
+		Files: []PipelineFile{{
+			Template: "azure-pipelines.yml.tmpl",
+			Comment:  "#",
+			Path:     fixedPath("azure-pipelines.yml"),
+		}},
+		Secret:       macroVariable,
+		SecretsHint:  "as secret pipeline variables, and allow the build service to contribute to the repository",
+		ApprovalHint: "add an approval check to the %s environment under Pipelines > Environments",
+		PullRequests: true,
+		TokenHint:    "as a secret pipeline variable",
+	})
+
+	Register(&Platform{
+		Name:        "bitbucket",
+		Description: "Bitbucket Pipelines",
+		Files: []PipelineFile{{
+			Template: "bitbucket-pipelines.yml.tmpl",
+			Comment:  "#",
+			Path:     fixedPath("bitbucket-pipelines.yml"),
+		}},
+		Secret:       envVariable,
+		SecretsHint:  "as secured repository variables in Bitbucket, and create a deployment environment named after each environment",
+		ApprovalHint: "run the 'Deploy to %s' step from the pipeline page",
+	})
+
+	Register(&Platform{
+		Name:        "circleci",
+		Description: "CircleCI",
+		Files: []PipelineFile{{
+			Template: "circleci.yml.tmpl",
+			Comment:  "#",
+			Path:     fixedPath(filepath.Join(".circleci", "config.yml")),
+		}},
+		Secret:       envVariable,
+		SecretsHint:  "as project environment variables in CircleCI, and add a deploy key with write access",
+		ApprovalHint: "approve the hold-%s job in the workflow",
+		PullRequests: true,
+		TokenHint:    "as a project environment variable in CircleCI",
+	})
+
+	Register(&Platform{
+		Name:        "tekton",
+		Description: "Tekton",
+		Files: []PipelineFile{{
+			Template: "tekton.yaml.tmpl",
+			Comment:  "#",
+			Path:     fixedPath(filepath.Join(".tekton", "pipeline.yaml")),
+		}},
+		Secret:       envVariable,
+		SecretsHint:  "as keys of a 'registry-credentials' Secret in the pipeline namespace, and provide Git credentials to the git-credentials workspace",
+		ApprovalHint: "start a PipelineRun with the approve-%s param set to true",
+		PullRequests: true,
+		TokenHint:    "as a key of a 'gitops-token' Secret in the pipeline namespace",
+	})
+}
@@ -0,0 +1,106 @@
+package ci
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PreviewOptions selects whether pipelines deploy ephemeral preview environments of pull requests
+type PreviewOptions struct {
+	Enabled bool
+	// From is the environment whose overlay previews are created from, the first one by default
+	From   string
+	Domain string
+	TTL    time.Duration
+}
+
+// Preview describes the preview environments pipelines deploy with troyops preview
+type Preview struct {
+	Enabled bool
+	From    string
+	Domain  string
+	TTL     string
+	// Provider hosts the pull requests the preview URL is posted on
+	Provider string
+}
+
+// newPreview resolves the preview options for a platform and its deployment stages
+func newPreview(opts PreviewOptions, p *Platform, envs []Environment) (Preview, error) {
+	if !opts.Enabled {
+		return Preview{}, nil
+	}
+	if !p.Previews {
+		return Preview{}, fmt.Errorf("%s cannot deploy preview environments (available on: github, gitlab)", p.Description)
+	}
+	if opts.TTL <= 0 {
+		return Preview{}, fmt.Errorf("--preview-ttl must be positive, got %s", opts.TTL)
+	}
+
+	from := opts.From
+	if from == "" {
+		from = envs[0].Name
+	}
+	found := false
+	for _, env := range envs {
+		found = found || env.Name == from
+	}
+	if !found {
+		return Preview{}, fmt.Errorf("preview source environment '%s' is not defined in troyops.yaml", from)
+	}
+
+	return Preview{Enabled: true, From: from, Domain: opts.Domain, TTL: opts.TTL.String(), Provider: p.Name}, nil
+}
+
+// upScript returns the troyops command deploying the preview of pull request pr with image@digest.
+// pr and digest are expressions in the platform's syntax.
+func (p Preview) upScript(image, pr, digest string) string {
+	args := []string{
+		"/tmp/troyops/bin/troyops", "preview", "up",
+		"--pr", pr,
+		"--from", p.From,
+		"--image", image + "@" + digest,
+		"--ttl", p.TTL,
+	}
+	if p.Domain != "" {
+		args = append(args, "--domain", p.Domain)
+	}
+	args = append(args, "--comment", "--provider", p.Provider)
+	return strings.Join(args, " ")
+}
+
+// downScript returns the troyops command removing the preview of pull request pr.
+// comment posts the removal on the pull request, which needs the repository checked out.
+func (p Preview) downScript(pr string, comment bool) string {
+	args := []string{"/tmp/troyops/bin/troyops", "preview", "down", "--pr", pr}
+	if comment {
+		args = append(args, "--comment", "--provider", p.Provider)
+	}
+	return strings.Join(args, " ")
+}
+
+// gcScript returns the troyops command removing expired previews
+func (p Preview) gcScript() string {
+	return "/tmp/troyops/bin/troyops preview gc"
+}
+
+// checklist returns the secrets preview jobs need and notes on setting them up.
+// gitOps is consulted so that a provider token already listed for deployment pull requests is not repeated.
+func (p Preview) checklist(platform string, gitOps GitOps) ([]requiredSecret, []string) {
+	if !p.Enabled {
+		return nil, nil
+	}
+	secrets := []requiredSecret{{"KUBE_CONFIG", "kubeconfig of the cluster previews are deployed to, allowed to create and delete namespaces"}}
+	var notes []string
+	switch platform {
+	case "github":
+		notes = append(notes, "secrets are not passed to workflows of pull requests from forks, so those get no preview")
+	case "gitlab":
+		secrets[0].Description += "; a variable of type File"
+		if gitOps.TokenVariable != "GITLAB_TOKEN" {
+			secrets = append(secrets, requiredSecret{"GITLAB_TOKEN", "GitLab project access token with the api scope, used to post the preview URL on merge requests"})
+		}
+		notes = append(notes, "create a pipeline schedule, e.g. hourly, to run preview-gc and remove expired previews")
+	}
+	return secrets, notes
+}
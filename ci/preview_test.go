@@ -0,0 +1,60 @@
+package ci
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewPreview(t *testing.T) {
+	envs := []Environment{{Name: "dev"}, {Name: "staging"}, {Name: "prod"}}
+	tests := []struct {
+		name     string
+		opts     PreviewOptions
+		platform string
+		from     string
+		wantErr  bool
+	}{
+		{
+			name:     "disabled",
+			platform: "jenkins",
+		},
+		{
+			name:     "defaults to the first environment",
+			opts:     PreviewOptions{Enabled: true, TTL: 72 * time.Hour},
+			platform: "github",
+			from:     "dev",
+		},
+		{
+			name:     "configured source environment",
+			opts:     PreviewOptions{Enabled: true, From: "staging", TTL: time.Hour},
+			platform: "gitlab",
+			from:     "staging",
+		},
+		{
+			name:     "unknown source environment",
+			opts:     PreviewOptions{Enabled: true, From: "qa", TTL: time.Hour},
+			platform: "github",
+			wantErr:  true,
+		},
+		{
+			name:     "platform without previews",
+			opts:     PreviewOptions{Enabled: true, TTL: time.Hour},
+			platform: "jenkins",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPreview(tt.opts, platforms[tt.platform], envs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPreview() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.From != tt.from {
+				t.Errorf("From = %q, want %q", got.From, tt.from)
+			}
+			if got.Enabled && got.Provider != tt.platform {
+				t.Errorf("Provider = %q, want %q", got.Provider, tt.platform)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package ci
+
+import (
+	"fmt"
+
+	"github.com/jefftrojan/troyops/config"
+)
+
+// Promotions select when a pipeline deploys an environment
+const (
+	// PromoteBranch deploys every build of the deploy branch
+	PromoteBranch = "branch"
+	// PromoteTag deploys release tags vX.Y.Z
+	PromoteTag = "tag"
+	// PromoteManual deploys the build of the previous environment after approval
+	PromoteManual = "manual"
+)
+
+// defaultPromotion returns the promotion of an environment without one configured
+func defaultPromotion(env config.Environment, index int) string {
+	switch {
+	case env.Name == "prod" || env.Name == "production":
+		if index > 0 {
+			return PromoteManual
+		}
+		return PromoteTag
+	case index == 0:
+		return PromoteBranch
+	default:
+		return PromoteTag
+	}
+}
+
+// promotionStages builds the deployment stages of the configured environments, in order.
+// Manual environments follow the environment before them and deploy the same build.
+// After links environments deployed by the same kind of build, so that they deploy one after the other.
+func promotionStages(envs []config.Environment) ([]Environment, error) {
+	var stages []Environment
+	for i, env := range envs {
+		promotion := env.Promotion
+		if promotion == "" {
+			promotion = defaultPromotion(env, i)
+		}
+
+		stage := Environment{Name: env.Name, Overlay: env.Overlay}
+		switch promotion {
+		case PromoteBranch, PromoteTag:
+			stage.Trigger = promotion
+		case PromoteManual:
+			if i == 0 {
+				return nil, fmt.Errorf("environment '%s' cannot be promoted manually: it has no previous environment to promote from", env.Name)
+			}
+			stage.Trigger = stages[i-1].Trigger
+			stage.Manual = true
+		default:
+			return nil, fmt.Errorf("environment '%s' has unsupported promotion '%s' (available: %s, %s, %s)", env.Name, promotion, PromoteBranch, PromoteTag, PromoteManual)
+		}
+		if i > 0 && stages[i-1].Trigger == stage.Trigger {
+			stage.After = stages[i-1].Name
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
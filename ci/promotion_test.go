@@ -0,0 +1,71 @@
+package ci
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jefftrojan/troyops/config"
+)
+
+func TestPromotionStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    []config.Environment
+		want    []Environment
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			envs: []config.Environment{{Name: "dev"}, {Name: "staging"}, {Name: "prod"}},
+			want: []Environment{
+				{Name: "dev", Trigger: PromoteBranch},
+				{Name: "staging", Trigger: PromoteTag},
+				{Name: "prod", Trigger: PromoteTag, Manual: true, After: "staging"},
+			},
+		},
+		{
+			name: "prod promoted from dev",
+			envs: []config.Environment{{Name: "dev"}, {Name: "prod"}},
+			want: []Environment{
+				{Name: "dev", Trigger: PromoteBranch},
+				{Name: "prod", Trigger: PromoteBranch, Manual: true, After: "dev"},
+			},
+		},
+		{
+			name: "configured promotion",
+			envs: []config.Environment{{Name: "dev", Promotion: PromoteTag}, {Name: "qa", Promotion: PromoteTag}},
+			want: []Environment{
+				{Name: "dev", Trigger: PromoteTag},
+				{Name: "qa", Trigger: PromoteTag, After: "dev"},
+			},
+		},
+		{
+			name:    "manual first environment",
+			envs:    []config.Environment{{Name: "dev", Promotion: PromoteManual}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown promotion",
+			envs:    []config.Environment{{Name: "dev", Promotion: "nightly"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promotionStages(tt.envs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
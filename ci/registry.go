@@ -0,0 +1,216 @@
+package ci
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// registryProvider describes how pipelines log in to a kind of container registry and name images in it
+type registryProvider struct {
+	Name        string
+	Description string
+	// Host is the default registry host; empty when --registry-host is required
+	Host string
+	// NamespaceRequired is set when images must live under a project or organization
+	NamespaceRequired bool
+	Secrets           []requiredSecret
+	// Builtin holds credentials the CI platform provides, by platform name
+	Builtin map[string]credentials
+	Notes   []string
+}
+
+// requiredSecret is a CI secret the user must configure for the registry
+type requiredSecret struct {
+	Name        string
+	Description string
+}
+
+// credentials are raw references to a registry username and password in a platform's syntax
+type credentials struct {
+	Username string
+	Password string
+	Source   string
+}
+
+// ecrHostPattern matches an Amazon ECR registry host and captures its region
+var ecrHostPattern = regexp.MustCompile(`^\d{12}\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com$`)
+
+// registries holds the supported container registries by name
+var registries = map[string]*registryProvider{
+	"dockerhub": {
+		Name:        "dockerhub",
+		Description: "Docker Hub",
+		Host:        "docker.io",
+		Secrets: []requiredSecret{
+			{"DOCKER_HUB_USERNAME", "Docker Hub account name, also used as the image namespace unless --registry-namespace is set"},
+			{"DOCKER_HUB_TOKEN", "Docker Hub access token with read and write scope"},
+		},
+	},
+	"ghcr": {
+		Name:        "ghcr",
+		Description: "GitHub Container Registry",
+		Host:        "ghcr.io",
+		Secrets: []requiredSecret{
+			{"GHCR_USERNAME", "GitHub user owning the token"},
+			{"GHCR_TOKEN", "GitHub personal access token with the write:packages scope"},
+		},
+		Builtin: map[string]credentials{
+			"github": {Username: "${{ github.actor }}", Password: "${{ secrets.GITHUB_TOKEN }}", Source: "the workflow's GITHUB_TOKEN"},
+		},
+	},
+	"gitlab": {
+		Name:        "gitlab",
+		Description: "GitLab Container Registry",
+		Host:        "registry.gitlab.com",
+		Secrets: []requiredSecret{
+			{"GITLAB_REGISTRY_USERNAME", "GitLab user or deploy token username"},
+			{"GITLAB_REGISTRY_TOKEN", "GitLab deploy token or access token with the write_registry scope"},
+		},
+		Builtin: map[string]credentials{
+			"gitlab": {Username: "$CI_REGISTRY_USER", Password: "$CI_REGISTRY_PASSWORD", Source: "the job's CI_REGISTRY_USER and CI_REGISTRY_PASSWORD"},
+		},
+	},
+	"ecr": {
+		Name:        "ecr",
+		Description: "Amazon ECR",
+		Secrets: []requiredSecret{
+			{"AWS_ACCESS_KEY_ID", "access key ID of an IAM user allowed to push to the repository"},
+			{"AWS_SECRET_ACCESS_KEY", "secret access key of that IAM user"},
+		},
+		Notes: []string{"ECR does not create repositories on push; create the repository before the first run"},
+	},
+	"gcr": {
+		Name:              "gcr",
+		Description:       "Google Container Registry",
+		Host:              "gcr.io",
+		NamespaceRequired: true,
+		Secrets: []requiredSecret{
+			{"GCR_USERNAME", "the literal value _json_key"},
+			{"GCR_JSON_KEY", "JSON key of a service account with the Storage Admin role on the project"},
+		},
+	},
+	"acr": {
+		Name:        "acr",
+		Description: "Azure Container Registry",
+		Secrets: []requiredSecret{
+			{"ACR_USERNAME", "application ID of a service principal with the AcrPush role"},
+			{"ACR_PASSWORD", "client secret of that service principal"},
+		},
+	},
+	"harbor": {
+		Name:              "harbor",
+		Description:       "Harbor",
+		NamespaceRequired: true,
+		Secrets: []requiredSecret{
+			{"HARBOR_USERNAME", "name of a robot account with push permission on the project"},
+			{"HARBOR_PASSWORD", "secret of that robot account"},
+		},
+	},
+	"custom": {
+		Name:        "custom",
+		Description: "container registry",
+		Secrets: []requiredSecret{
+			{"REGISTRY_USERNAME", "registry username"},
+			{"REGISTRY_PASSWORD", "registry password or token"},
+		},
+	},
+}
+
+// Registries returns the names of the supported container registries in order
+func Registries() []string {
+	names := make([]string, 0, len(registries))
+	for name := range registries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegistryOptions selects the container registry pipelines push to
+type RegistryOptions struct {
+	Name      string
+	Host      string
+	Namespace string
+}
+
+// newRegistry resolves the registry options into template data for a platform.
+// repoURL is used to default the namespace of GitHub and GitLab registries.
+func newRegistry(opts RegistryOptions, platform, repoURL string) (Registry, error) {
+	provider, ok := registries[opts.Name]
+	if !ok {
+		return Registry{}, fmt.Errorf("unsupported registry: %s (available: %s)", opts.Name, strings.Join(Registries(), ", "))
+	}
+
+	r := Registry{
+		Name:           provider.Name,
+		Description:    provider.Description,
+		Host:           provider.Host,
+		Namespace:      strings.Trim(opts.Namespace, "/"),
+		UsernameSecret: provider.Secrets[0].Name,
+		PasswordSecret: provider.Secrets[1].Name,
+	}
+	if opts.Host != "" {
+		r.Host = strings.TrimSuffix(opts.Host, "/")
+	}
+	if r.Host == "" {
+		return Registry{}, fmt.Errorf("--registry-host is required for %s", provider.Description)
+	}
+
+	if provider.Name == "ecr" {
+		match := ecrHostPattern.FindStringSubmatch(r.Host)
+		if match == nil {
+			return Registry{}, fmt.Errorf("%s is not an ECR registry host (<account>.dkr.ecr.<region>.amazonaws.com)", r.Host)
+		}
+		r.Region = match[1]
+	}
+
+	if r.Namespace == "" && (provider.Name == "ghcr" || provider.Name == "gitlab") {
+		r.Namespace = repositoryNamespace(provider.Name, repoURL)
+	}
+	if r.Namespace == "" && provider.NamespaceRequired {
+		return Registry{}, fmt.Errorf("--registry-namespace is required for %s", provider.Description)
+	}
+
+	if builtin, ok := provider.Builtin[platform]; ok {
+		r.Username = builtin.Username
+		r.Password = builtin.Password
+	}
+	return r, nil
+}
+
+// repositoryNamespace derives the image namespace from the Git repository URL:
+// the owner for GitHub and the project path for GitLab, lowercased as registries require
+func repositoryNamespace(registry, repoURL string) string {
+	path := repoURL
+	if strings.Contains(repoURL, "://") {
+		u, err := url.Parse(repoURL)
+		if err != nil {
+			return ""
+		}
+		path = u.Path
+	} else if colon := strings.Index(repoURL, ":"); colon >= 0 {
+		// scp-style: git@host:owner/repository.git
+		path = repoURL[colon+1:]
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if !strings.Contains(path, "/") {
+		return ""
+	}
+	if registry == "ghcr" {
+		path = path[:strings.Index(path, "/")]
+	}
+	return strings.ToLower(path)
+}
+
+// checklist returns the secrets the user must configure for a registry on a platform,
+// or the source of the platform's built-in credentials
+func checklist(r Registry, platform string) ([]requiredSecret, string, []string) {
+	provider := registries[r.Name]
+	if builtin, ok := provider.Builtin[platform]; ok {
+		return nil, builtin.Source, provider.Notes
+	}
+	return provider.Secrets, "", provider.Notes
+}
@@ -0,0 +1,87 @@
+package ci
+
+import "testing"
+
+func TestNewRegistry(t *testing.T) {
+	const repoURL = "git@github.com:JeffTrojan/troyops.git"
+
+	tests := []struct {
+		name     string
+		opts     RegistryOptions
+		platform string
+		image    string
+		login    string
+		wantErr  bool
+	}{
+		{
+			name:     "docker hub namespace defaults to the username",
+			opts:     RegistryOptions{Name: "dockerhub"},
+			platform: "gitlab",
+			image:    "docker.io/$DOCKER_HUB_USERNAME/demo",
+			login:    `echo "$DOCKER_HUB_TOKEN" | docker login -u "$DOCKER_HUB_USERNAME" --password-stdin docker.io`,
+		},
+		{
+			name:     "ghcr uses the workflow token on github",
+			opts:     RegistryOptions{Name: "ghcr"},
+			platform: "github",
+			image:    "ghcr.io/jefftrojan/demo",
+			login:    `echo "${{ secrets.GITHUB_TOKEN }}" | docker login -u "${{ github.actor }}" --password-stdin ghcr.io`,
+		},
+		{
+			name:     "ghcr needs a token elsewhere",
+			opts:     RegistryOptions{Name: "ghcr", Namespace: "acme"},
+			platform: "gitlab",
+			image:    "ghcr.io/acme/demo",
+			login:    `echo "$GHCR_TOKEN" | docker login -u "$GHCR_USERNAME" --password-stdin ghcr.io`,
+		},
+		{
+			name:     "ecr logs in through the aws cli",
+			opts:     RegistryOptions{Name: "ecr", Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com"},
+			platform: "gitlab",
+			image:    "123456789012.dkr.ecr.eu-west-1.amazonaws.com/demo",
+			login:    "docker run --rm -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY amazon/aws-cli ecr get-login-password --region eu-west-1 | docker login -u AWS --password-stdin 123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+		},
+		{
+			name:    "ecr rejects other hosts",
+			opts:    RegistryOptions{Name: "ecr", Host: "registry.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "acr requires a host",
+			opts:    RegistryOptions{Name: "acr"},
+			wantErr: true,
+		},
+		{
+			name:    "harbor requires a project",
+			opts:    RegistryOptions{Name: "harbor", Host: "harbor.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown registry",
+			opts:    RegistryOptions{Name: "quay"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRegistry(tt.opts, tt.platform, repoURL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret := platforms[tt.platform].Secret
+			if got := r.image("demo", secret); got != tt.image {
+				t.Errorf("image = %q, want %q", got, tt.image)
+			}
+			if got := r.login(secret); got != tt.login {
+				t.Errorf("login = %q, want %q", got, tt.login)
+			}
+		})
+	}
+}
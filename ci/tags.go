@@ -0,0 +1,72 @@
+package ci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tag strategies select which immutable tag a pipeline deploys
+const (
+	// TagSHA deploys builds of the deploy branch as sha-<short sha>
+	TagSHA = "sha"
+	// TagSemver deploys only release tags
+	TagSemver = "semver"
+	// TagBranch deploys builds of the deploy branch as <branch>-<short sha>-<unix time>,
+	// the format flux image --policy regex orders by
+	TagBranch = "branch"
+)
+
+// TagStrategies lists the supported tag strategies
+var TagStrategies = []string{TagSHA, TagSemver, TagBranch}
+
+// validTagStrategy reports whether strategy is supported
+func validTagStrategy(strategy string) bool {
+	for _, s := range TagStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// tagScript returns POSIX shell computing the image tags of a build.
+// commit, branch and tag are the platform's expressions for the built commit, the pushed branch and
+// the pushed Git tag; branch and tag are empty when not set.
+// It sets SHORT_SHA, IMAGE_TAGS (space separated) and DEPLOY_TAG, which is empty when the build is not deployed.
+// Release tags vX.Y.Z are deployed as X.Y.Z with every strategy.
+func tagScript(strategy, commit, branch, tag string) string {
+	var deploy string
+	switch strategy {
+	case TagSemver:
+		deploy = `DEPLOY_TAG="$VERSION"`
+	case TagBranch:
+		deploy = `DEPLOY_TAG="$VERSION$BRANCH_TAG"`
+	default:
+		deploy = `DEPLOY_TAG="$VERSION"
+if [ -n "$BRANCH_TAG" ]; then
+  DEPLOY_TAG="sha-$SHORT_SHA"
+fi`
+	}
+
+	return fmt.Sprintf(`BRANCH="%s"
+GIT_TAG="%s"
+SHORT_SHA=$(echo "%s" | cut -c1-7)
+IMAGE_TAGS="sha-$SHORT_SHA"
+VERSION=""
+BRANCH_TAG=""
+if [ -n "$GIT_TAG" ]; then
+  if echo "$GIT_TAG" | grep -Eq '^v?[0-9]+[.][0-9]+[.][0-9]+'; then
+    VERSION="${GIT_TAG#v}"
+    IMAGE_TAGS="$IMAGE_TAGS $VERSION"
+  fi
+elif [ -n "$BRANCH" ]; then
+  BRANCH_TAG="$(echo "$BRANCH" | sed 's/[^A-Za-z0-9_.-]/-/g')-$SHORT_SHA-$(date +%%s)"
+  IMAGE_TAGS="$IMAGE_TAGS $BRANCH_TAG"
+fi
+%s`, branch, tag, commit, deploy)
+}
+
+// indent prefixes every line but the first with n spaces, for multi-line values in YAML blocks
+func indent(n int, s string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+strings.Repeat(" ", n))
+}
@@ -0,0 +1,38 @@
+package ci
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTagScript(t *testing.T) {
+	tests := []struct {
+		strategy string
+		branch   string
+		tag      string
+		deploy   string
+	}{
+		{TagSHA, "main", "", "sha-0123456"},
+		{TagSHA, "", "v1.2.3", "1.2.3"},
+		{TagSemver, "main", "", ""},
+		{TagSemver, "", "v1.2.3", "1.2.3"},
+		{TagSemver, "", "nightly", ""},
+		{TagBranch, "feature/login", "", "feature-login-0123456-"},
+		{TagBranch, "", "v1.2.3", "1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy+"/"+tt.branch+tt.tag, func(t *testing.T) {
+			script := tagScript(tt.strategy, "0123456789abcdef", tt.branch, tt.tag) + "\necho \"$DEPLOY_TAG\""
+			out, err := exec.Command("sh", "-c", script).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.TrimSpace(string(out))
+			if tt.deploy == "" && got != "" || !strings.HasPrefix(got, tt.deploy) {
+				t.Errorf("deploy tag = %q, want %q", got, tt.deploy)
+			}
+		})
+	}
+}
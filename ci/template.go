@@ -0,0 +1,212 @@
+package ci
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/jefftrojan/troyops/config"
+)
+
+// templates holds the built-in pipeline templates
+//
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// OverrideDir is where a project keeps templates replacing the built-in ones, relative to the repository
+var OverrideDir = filepath.Join(".troyops", "templates")
+
+// Template actions use [[ ]] so that ${{ }} expressions of CI platforms pass through
+const (
+	leftDelim  = "[["
+	rightDelim = "]]"
+)
+
+// Data is the model the pipeline templates are rendered with
+type Data struct {
+	App          string
+	Registry     Registry
+	Image        string
+	Environments []Environment
+	Branches     []string
+	// TagStrategy selects which immutable tag is deployed, see TagStrategies
+	TagStrategy string
+	GitOps      GitOps
+	Preview     Preview
+	// TroyopsRef is the commit SHA or release tag of troyops pull request and preview jobs install
+	TroyopsRef string
+}
+
+// Registry describes the container registry images are pushed to
+type Registry struct {
+	Name        string
+	Description string
+	Host        string
+	// Namespace is the path images live under; empty for Docker Hub means the account name
+	Namespace      string
+	UsernameSecret string
+	PasswordSecret string
+	// Username and Password are credentials provided by the platform, overriding the secrets
+	Username string
+	Password string
+	// Region is the AWS region of an ECR registry
+	Region string
+}
+
+// user references the registry username in the platform's syntax
+func (r Registry) user(secret func(string) string) string {
+	if r.Username != "" {
+		return r.Username
+	}
+	return secret(r.UsernameSecret)
+}
+
+// password references the registry password in the platform's syntax
+func (r Registry) password(secret func(string) string) string {
+	if r.Password != "" {
+		return r.Password
+	}
+	return secret(r.PasswordSecret)
+}
+
+// image returns the fully qualified image repository
+func (r Registry) image(name string, secret func(string) string) string {
+	namespace := r.Namespace
+	if namespace == "" && r.Name == "dockerhub" {
+		namespace = r.user(secret)
+	}
+	if namespace == "" {
+		return r.Host + "/" + name
+	}
+	return r.Host + "/" + namespace + "/" + name
+}
+
+// login returns the shell command logging docker in to the registry.
+// ECR passwords are fetched with the AWS CLI image so that build images need nothing but docker.
+func (r Registry) login(secret func(string) string) string {
+	if r.Name == "ecr" {
+		return fmt.Sprintf("docker run --rm -e %s -e %s amazon/aws-cli ecr get-login-password --region %s | docker login -u AWS --password-stdin %s",
+			r.UsernameSecret, r.PasswordSecret, r.Region, r.Host)
+	}
+	return fmt.Sprintf(`echo "%s" | docker login -u "%s" --password-stdin %s`, r.password(secret), r.user(secret), r.Host)
+}
+
+// Environment is a deployment stage of the pipeline updating the overlay of an environment
+type Environment struct {
+	Name    string
+	Overlay string
+	// Trigger is the kind of build deploying the environment, PromoteBranch or PromoteTag
+	Trigger string
+	// Manual is set when the deployment waits for approval
+	Manual bool
+	// After is the environment deployed before this one, empty for the first
+	After string
+}
+
+// newData builds the template data for an application on a platform from the project config
+func newData(appName string, p *Platform, registry RegistryOptions, tagStrategy string, gitOps GitOpsOptions, preview PreviewOptions, troyopsRef string) (*Data, error) {
+	if !validTagStrategy(tagStrategy) {
+		return nil, fmt.Errorf("unsupported tag strategy: %s (available: %s)", tagStrategy, strings.Join(TagStrategies, ", "))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newRegistry(registry, p.Name, cfg.Repository.URL)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newGitOps(gitOps, p, cfg.Repository.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Data{
+		App:         appName,
+		Registry:    r,
+		Image:       appName,
+		Branches:    []string{cfg.Repository.Branch},
+		TagStrategy: tagStrategy,
+		GitOps:      g,
+	}
+	envs := cfg.Environments
+	if len(envs) == 0 {
+		envs = []config.Environment{{Name: "dev", Overlay: "./kustomize/overlays/dev"}}
+	}
+	if data.Environments, err = promotionStages(envs); err != nil {
+		return nil, err
+	}
+	if data.Preview, err = newPreview(preview, p, data.Environments); err != nil {
+		return nil, err
+	}
+	if data.GitOps.PullRequests() || data.Preview.Enabled {
+		if err := validTroyopsRef(troyopsRef); err != nil {
+			return nil, err
+		}
+		data.TroyopsRef = troyopsRef
+	}
+
+	return data, nil
+}
+
+// render executes a pipeline template, preferring an override in the repository.
+// secret renders a reference to a CI secret or variable in the platform's syntax.
+func render(repoPath, name string, secret func(string) string, data *Data) ([]byte, error) {
+	source, err := os.ReadFile(filepath.Join(repoPath, OverrideDir, name))
+	if os.IsNotExist(err) {
+		source, err = templates.ReadFile("templates/" + name)
+	} else if err == nil {
+		fmt.Printf("Using template override %s\n", filepath.Join(repoPath, OverrideDir, name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// installed prefixes a troyops command with the install of the pinned CLI
+	installed := func(command string) string { return troyopsInstall(data.TroyopsRef) + "\n" + command }
+
+	funcs := template.FuncMap{
+		"secret":           secret,
+		"join":             strings.Join,
+		"image":            func() string { return data.Registry.image(data.Image, secret) },
+		"login":            func() string { return data.Registry.login(secret) },
+		"registryUser":     func() string { return data.Registry.user(secret) },
+		"registryPassword": func() string { return data.Registry.password(secret) },
+		"tags":             func(commit, branch, tag string) string { return tagScript(data.TagStrategy, commit, branch, tag) },
+		"indent":           indent,
+		"pullRequest": func(environment, overlay, tag, digest string) string {
+			return installed(data.GitOps.pullRequestScript(data.App, data.Registry.image(data.Image, secret), environment, overlay, tag, digest, data.Branches[0]))
+		},
+		"previewUp": func(pr, digest string) string {
+			return installed(data.Preview.upScript(data.Registry.image(data.Image, secret), pr, digest))
+		},
+		"previewDown": func(pr string, comment bool) string { return installed(data.Preview.downScript(pr, comment)) },
+		"previewGC":   func() string { return installed(data.Preview.gcScript()) },
+	}
+	tmpl, err := template.New(name).Delims(leftDelim, rightDelim).Funcs(funcs).Option("missingkey=error").Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %v", name, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("rendering template %s: %v", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// githubSecret references a GitHub Actions secret
+func githubSecret(name string) string {
+	return "${{ secrets." + name + " }}"
+}
+
+// gitlabVariable references a GitLab CI/CD variable
+func gitlabVariable(name string) string {
+	return "$" + name
+}
@@ -0,0 +1,138 @@
+package ci
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the rendered pipelines")
+
+// testData is the model the golden files are rendered with
+func testData() *Data {
+	return &Data{
+		App: "demo",
+		Registry: Registry{
+			Name:           "dockerhub",
+			Description:    "Docker Hub",
+			Host:           "docker.io",
+			UsernameSecret: "DOCKER_HUB_USERNAME",
+			PasswordSecret: "DOCKER_HUB_TOKEN",
+		},
+		Image: "demo",
+		Environments: []Environment{
+			{Name: "dev", Overlay: "./kustomize/overlays/dev", Trigger: PromoteBranch},
+			{Name: "staging", Overlay: "./kustomize/overlays/staging", Trigger: PromoteTag},
+			{Name: "prod", Overlay: "./kustomize/overlays/prod", Trigger: PromoteTag, Manual: true, After: "staging"},
+		},
+		Branches:    []string{"main"},
+		TagStrategy: TagSHA,
+		GitOps:      GitOps{Mode: GitOpsPush},
+		TroyopsRef:  "0123456789abcdef0123456789abcdef01234567",
+	}
+}
+
+// checkGolden compares rendered output with testdata/<name>, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run go test ./ci -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("rendered %s differs from %s:\n%s", name, golden, got)
+	}
+}
+
+func TestPipelineTemplates(t *testing.T) {
+	for _, name := range Platforms() {
+		platform := platforms[name]
+		for _, file := range platform.Files {
+			if file.Enabled != nil && !file.Enabled(testData()) {
+				continue
+			}
+			t.Run(name+"/"+file.Template, func(t *testing.T) {
+				got, err := render(t.TempDir(), file.Template, platform.Secret, testData())
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, strings.TrimSuffix(file.Template, ".tmpl")+".golden", got)
+			})
+		}
+	}
+}
+
+func TestPullRequestTemplates(t *testing.T) {
+	for _, name := range Platforms() {
+		platform := platforms[name]
+		if !platform.PullRequests {
+			continue
+		}
+		data := testData()
+		data.GitOps = GitOps{Mode: GitOpsPR, Labels: []string{"deploy"}, AutoMerge: true, MergeMethod: "squash", Provider: "github", TokenVariable: "GITHUB_TOKEN"}
+		if name == "gitlab" {
+			data.GitOps.Provider, data.GitOps.TokenVariable = "gitlab", "GITLAB_TOKEN"
+		}
+		for _, file := range platform.Files {
+			if file.Enabled != nil && !file.Enabled(data) {
+				continue
+			}
+			t.Run(name+"/"+file.Template, func(t *testing.T) {
+				got, err := render(t.TempDir(), file.Template, platform.Secret, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, strings.TrimSuffix(file.Template, ".tmpl")+".pr.golden", got)
+			})
+		}
+	}
+}
+
+func TestPreviewTemplates(t *testing.T) {
+	for _, name := range Platforms() {
+		platform := platforms[name]
+		if !platform.Previews {
+			continue
+		}
+		data := testData()
+		data.Preview = Preview{Enabled: true, From: "dev", Domain: "preview.example.com", TTL: "72h0m0s", Provider: name}
+		for _, file := range platform.Files {
+			t.Run(name+"/"+file.Template, func(t *testing.T) {
+				got, err := render(t.TempDir(), file.Template, platform.Secret, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, strings.TrimSuffix(file.Template, ".tmpl")+".preview.golden", got)
+			})
+		}
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	repoPath := t.TempDir()
+	dir := filepath.Join(repoPath, OverrideDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	override := "app: [[ .App ]]\nuser: [[ secret .Registry.UsernameSecret ]]\n"
+	if err := os.WriteFile(filepath.Join(dir, "gitlab-ci.yml.tmpl"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := render(repoPath, "gitlab-ci.yml.tmpl", gitlabVariable, testData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "app: demo\nuser: $DOCKER_HUB_USERNAME\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jefftrojan/troyops/charts"
 	"github.com/jefftrojan/troyops/ci"
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/convert"
 	"github.com/jefftrojan/troyops/flux"
+	"github.com/jefftrojan/troyops/gitops"
 	"github.com/jefftrojan/troyops/kustomize"
+	"github.com/jefftrojan/troyops/parity"
 	"github.com/jefftrojan/troyops/policies"
+	"github.com/jefftrojan/troyops/preview"
 	"github.com/jefftrojan/troyops/secrets"
 	"github.com/spf13/cobra"
 )
@@ -22,12 +28,20 @@ func main() {
 		},
 	}
 
+	// Add global flags
+	rootCmd.PersistentFlags().StringVar(&config.File, "config", config.DefaultFile, "Path to the project config file")
+
 	// Add subcommands for different functionalities
 	rootCmd.AddCommand(flux.SetupFluxCmd())
 	rootCmd.AddCommand(ci.SetupCICDCmd())
 	rootCmd.AddCommand(kustomize.DeployManifestsCmd())
 	rootCmd.AddCommand(secrets.ConfigureSecretsCmd())
 	rootCmd.AddCommand(policies.SetupPoliciesCmd())
+	rootCmd.AddCommand(charts.ChartCmd())
+	rootCmd.AddCommand(parity.ParityCmd())
+	rootCmd.AddCommand(convert.ConvertCmd())
+	rootCmd.AddCommand(gitops.GitOpsCmd())
+	rootCmd.AddCommand(preview.PreviewCmd())
 
 	// Execute the root command
 	if err := rootCmd.Execute(); err != nil {
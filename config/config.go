@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the default path of the project configuration file
+const DefaultFile = "troyops.yaml"
+
+// File is the path of the project configuration file, set by the --config flag
+var File = DefaultFile
+
+// Config is the TroyOps project configuration
+type Config struct {
+	App          string        `yaml:"app"`
+	Repository   Repository    `yaml:"repository"`
+	Flux         Flux          `yaml:"flux"`
+	Secrets      Secrets       `yaml:"secrets"`
+	Environments []Environment `yaml:"environments"`
+}
+
+// Repository describes the Git repository Flux syncs from
+type Repository struct {
+	URL       string `yaml:"url"`
+	Branch    string `yaml:"branch"`
+	SecretRef string `yaml:"secretRef,omitempty"`
+}
+
+// Flux holds the Flux installation settings
+type Flux struct {
+	Namespace string `yaml:"namespace"`
+	Path      string `yaml:"path"`
+}
+
+// Secrets selects how secrets committed to the repository are encrypted
+type Secrets struct {
+	Engine string `yaml:"engine"`
+}
+
+// Environment describes a deployment environment and how Flux reconciles it
+type Environment struct {
+	Name         string        `yaml:"name"`
+	Overlay      string        `yaml:"overlay"`
+	Namespace    string        `yaml:"namespace"`
+	Interval     string        `yaml:"interval"`
+	Timeout      string        `yaml:"timeout,omitempty"`
+	Prune        *bool         `yaml:"prune,omitempty"`
+	DependsOn    []string      `yaml:"dependsOn,omitempty"`
+	HealthChecks []HealthCheck `yaml:"healthChecks,omitempty"`
+	// Promotion selects when generated pipelines deploy the environment: branch, tag or manual.
+	// By default the first environment deploys builds of the branch, prod and production
+	// need manual approval and the others deploy release tags.
+	Promotion string `yaml:"promotion,omitempty"`
+}
+
+// HealthCheck references an object Flux waits on after applying an environment
+type HealthCheck struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// Load reads the project configuration from File and fills in defaults.
+// A missing file is not an error; the defaults are derived from the repository layout.
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(File)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", File, err)
+		}
+	}
+
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Environment returns the environment with the given name
+func (c *Config) Environment(name string) (*Environment, error) {
+	for i := range c.Environments {
+		if c.Environments[i].Name == name {
+			return &c.Environments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("environment '%s' is not defined in %s", name, File)
+}
+
+// PruneEnabled reports whether Flux garbage collects objects removed from the environment
+func (e *Environment) PruneEnabled() bool {
+	return e.Prune == nil || *e.Prune
+}
+
+// setDefaults fills in unset fields
+func (c *Config) setDefaults() error {
+	if c.App == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		c.App = filepath.Base(wd)
+	}
+	if c.Repository.Branch == "" {
+		c.Repository.Branch = "main"
+	}
+	if c.Flux.Namespace == "" {
+		c.Flux.Namespace = "flux-system"
+	}
+	if c.Flux.Path == "" {
+		c.Flux.Path = filepath.Join("flux", "applications")
+	}
+	if c.Secrets.Engine == "" {
+		c.Secrets.Engine = "sops"
+	}
+
+	// Discover environments from the Kustomize overlays when none are configured
+	if len(c.Environments) == 0 {
+		entries, err := os.ReadDir(filepath.Join("kustomize", "overlays"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				c.Environments = append(c.Environments, Environment{Name: entry.Name()})
+			}
+		}
+		sort.Slice(c.Environments, func(i, j int) bool { return c.Environments[i].Name < c.Environments[j].Name })
+	}
+
+	for i := range c.Environments {
+		env := &c.Environments[i]
+		if env.Name == "" {
+			return fmt.Errorf("environment %d in %s has no name", i+1, File)
+		}
+		if env.Overlay == "" {
+			env.Overlay = "./" + filepath.ToSlash(filepath.Join("kustomize", "overlays", env.Name))
+		}
+		if env.Namespace == "" {
+			env.Namespace = env.Name
+		}
+		if env.Interval == "" {
+			env.Interval = "1m0s"
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jefftrojan/troyops/charts"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// helmPrefixes are label and annotation key prefixes owned by Helm
+var helmPrefixes = []string{"helm.sh/", "meta.helm.sh/"}
+
+// kustomization is the generated kustomization.yaml
+type kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Namespace  string   `yaml:"namespace,omitempty"`
+	Resources  []string `yaml:"resources"`
+}
+
+// ConvertCmd defines the command for converting between delivery formats
+func ConvertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Convert manifests between delivery formats",
+		Long:  `Convert Kubernetes manifests between Helm charts and Kustomize bases.`,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(helmToKustomizeCmd())
+
+	return cmd
+}
+
+// helmToKustomizeCmd creates a command to render a Helm chart into a Kustomize base
+func helmToKustomizeCmd() *cobra.Command {
+	var chartPath string
+	var valuesFiles []string
+	var release string
+	var namespace string
+	var outDir string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "helm-to-kustomize",
+		Short: "Convert a Helm chart into a Kustomize base",
+		Long: `Render a Helm chart and write the resulting objects into a Kustomize base,
+one file per resource, together with a generated kustomization.yaml.
+Helm-specific labels and annotations are removed and test hooks are skipped.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			helmToKustomize(chartPath, valuesFiles, release, namespace, outDir, force)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&chartPath, "chart", "c", "", "Path to the Helm chart (required)")
+	cmd.Flags().StringSliceVarP(&valuesFiles, "values", "f", nil, "Helm values files to render the chart with")
+	cmd.Flags().StringVar(&release, "release", "troyops", "Helm release name used when rendering the chart")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace to render the chart for and set in the kustomization")
+	cmd.Flags().StringVarP(&outDir, "out", "o", filepath.Join("kustomize", "base"), "Directory to write the Kustomize base to")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing kustomization.yaml in the output directory")
+	cmd.MarkFlagRequired("chart")
+
+	return cmd
+}
+
+// helmToKustomize renders the chart and writes the objects as a Kustomize base
+func helmToKustomize(chartPath string, valuesFiles []string, release, namespace, outDir string, force bool) {
+	fmt.Printf("Converting chart %s into Kustomize base %s...\n", chartPath, outDir)
+
+	kustomizationFile := filepath.Join(outDir, "kustomization.yaml")
+	if _, err := os.Stat(kustomizationFile); err == nil && !force {
+		fmt.Printf("Error: %s already exists. Use --force to overwrite it.\n", kustomizationFile)
+		return
+	}
+
+	objects, err := charts.Render(chartPath, release, namespace, valuesFiles)
+	if err != nil {
+		fmt.Println("Error rendering Helm chart:", err)
+		return
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+
+	var kept []manifest.Object
+	for _, obj := range objects {
+		annotations, _ := obj.Metadata()["annotations"].(map[string]interface{})
+		hook, _ := annotations["helm.sh/hook"].(string)
+		if strings.Contains(hook, "test") {
+			fmt.Printf("Skipping Helm test hook %s\n", obj.ID())
+			continue
+		}
+		if hook != "" {
+			fmt.Printf("Warning: %s is a Helm %s hook and will be applied as a regular resource\n", obj.ID(), hook)
+		}
+
+		stripHelmMetadata(obj)
+		kept = append(kept, obj)
+	}
+
+	var resources []string
+	for i, fileName := range resourceFileNames(kept) {
+		obj := kept[i]
+		data, err := manifest.Encode(obj)
+		if err != nil {
+			fmt.Printf("Error encoding %s: %v\n", obj.ID(), err)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(outDir, fileName), data, 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", fileName, err)
+			return
+		}
+		fmt.Printf("Wrote %s\n", filepath.Join(outDir, fileName))
+		resources = append(resources, fileName)
+	}
+	sort.Strings(resources)
+
+	data, err := manifest.Encode(kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Namespace:  namespace,
+		Resources:  resources,
+	})
+	if err != nil {
+		fmt.Println("Error encoding kustomization.yaml:", err)
+		return
+	}
+	if err := os.WriteFile(kustomizationFile, data, 0644); err != nil {
+		fmt.Println("Error writing kustomization.yaml:", err)
+		return
+	}
+
+	fmt.Printf("Converted %d resources into %s\n", len(resources), outDir)
+}
+
+// stripHelmMetadata removes Helm-owned labels and annotations from the object and its pod template
+func stripHelmMetadata(obj manifest.Object) {
+	metadatas := []map[string]interface{}{obj.Metadata()}
+	if spec, ok := obj["spec"].(map[string]interface{}); ok {
+		if template, ok := spec["template"].(map[string]interface{}); ok {
+			if metadata, ok := template["metadata"].(map[string]interface{}); ok {
+				metadatas = append(metadatas, metadata)
+			}
+		}
+	}
+
+	for _, metadata := range metadatas {
+		for _, field := range []string{"labels", "annotations"} {
+			values, ok := metadata[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, value := range values {
+				if isHelmKey(key) || (key == "app.kubernetes.io/managed-by" && value == "Helm") {
+					delete(values, key)
+				}
+			}
+			if len(values) == 0 {
+				delete(metadata, field)
+			}
+		}
+	}
+}
+
+// isHelmKey reports whether a label or annotation key is owned by Helm
+func isHelmKey(key string) bool {
+	for _, prefix := range helmPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceFileName returns the file name for an object without extension, e.g. deployment-troyops.
+// withNamespace appends the namespace and withGroup qualifies the kind with its API group.
+func resourceFileName(obj manifest.Object, withNamespace, withGroup bool) string {
+	kind := strings.ToLower(obj.Kind())
+	if group, _, ok := strings.Cut(obj.APIVersion(), "/"); ok && withGroup {
+		kind += "." + group
+	}
+	name := kind + "-" + obj.Name()
+	if ns := obj.Namespace(); ns != "" && withNamespace {
+		name += "-" + ns
+	}
+	return name
+}
+
+// resourceFileNames returns a distinct file name for each object. Objects sharing kind and name
+// get their namespace, then their API group added; identical objects are numbered.
+func resourceFileNames(objects []manifest.Object) []string {
+	names := make([]string, len(objects))
+	for i, obj := range objects {
+		names[i] = resourceFileName(obj, false, false)
+	}
+	for _, group := range []bool{false, true} {
+		counts := map[string]int{}
+		for _, name := range names {
+			counts[name]++
+		}
+		for i, obj := range objects {
+			if counts[names[i]] > 1 {
+				names[i] = resourceFileName(obj, true, group)
+			}
+		}
+	}
+
+	used := map[string]bool{}
+	for i, name := range names {
+		unique := name
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s-%d", name, n)
+		}
+		used[unique] = true
+		names[i] = unique + ".yaml"
+	}
+	return names
+}
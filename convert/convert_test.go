@@ -0,0 +1,65 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+func TestResourceFileNames(t *testing.T) {
+	object := func(apiVersion, kind, namespace, name string) manifest.Object {
+		metadata := map[string]interface{}{"name": name}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+		return manifest.Object{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}
+	}
+
+	tests := []struct {
+		name    string
+		objects []manifest.Object
+		want    []string
+	}{
+		{
+			name: "distinct objects",
+			objects: []manifest.Object{
+				object("apps/v1", "Deployment", "", "web"),
+				object("v1", "Service", "", "web"),
+			},
+			want: []string{"deployment-web.yaml", "service-web.yaml"},
+		},
+		{
+			name: "same name in two namespaces",
+			objects: []manifest.Object{
+				object("v1", "ConfigMap", "dev", "settings"),
+				object("v1", "ConfigMap", "prod", "settings"),
+				object("v1", "Secret", "dev", "settings"),
+			},
+			want: []string{"configmap-settings-dev.yaml", "configmap-settings-prod.yaml", "secret-settings.yaml"},
+		},
+		{
+			name: "same kind in two API groups",
+			objects: []manifest.Object{
+				object("networking.k8s.io/v1", "Ingress", "", "web"),
+				object("extensions/v1beta1", "Ingress", "", "web"),
+			},
+			want: []string{"ingress.networking.k8s.io-web.yaml", "ingress.extensions-web.yaml"},
+		},
+		{
+			name: "identical objects",
+			objects: []manifest.Object{
+				object("v1", "ServiceAccount", "", "web"),
+				object("v1", "ServiceAccount", "", "web"),
+			},
+			want: []string{"serviceaccount-web.yaml", "serviceaccount-web-2.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resourceFileNames(tt.objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resourceFileNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
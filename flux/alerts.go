@@ -0,0 +1,192 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/jefftrojan/troyops/secrets"
+	"github.com/spf13/cobra"
+)
+
+// alertAddressEnv holds the webhook address when --address is not given
+const alertAddressEnv = "TROYOPS_ALERT_ADDRESS"
+
+// alertProviders are the notification provider types supported by alerts add
+var alertProviders = map[string]bool{"slack": true, "msteams": true, "generic": true}
+
+// alertsCmd creates a command to manage Flux notification alerts
+func alertsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "Manage Flux notification alerts",
+	}
+
+	cmd.AddCommand(alertsAddCmd())
+
+	return cmd
+}
+
+// alertsAddCmd creates a command to generate a Provider, Alert and Secret for an environment
+func alertsAddCmd() *cobra.Command {
+	var environment string
+	var provider string
+	var secretRef string
+	var severity string
+	var channel string
+	var address string
+	var decryptionSecret string
+	var outDir string
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Generate alerts for an environment's Kustomizations",
+		Long: `Generate a notification Provider and an Alert for the Kustomizations of an environment,
+together with the Secret holding the webhook address. The Secret is encrypted with the
+secrets engine from the project config and written to secrets/<engine>, which the generated
+<app>-secrets Kustomization applies. With SOPS it decrypts with the key in --decryption-secret.
+
+The webhook address is read from --address or the TROYOPS_ALERT_ADDRESS environment
+variable, so that it does not have to appear in the shell history.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if address == "" {
+				address = os.Getenv(alertAddressEnv)
+			}
+			addAlert(environment, provider, secretRef, severity, channel, address, decryptionSecret, outDir)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&environment, "environment", "e", "dev", "Environment whose Kustomizations are watched")
+	cmd.Flags().StringVar(&provider, "provider", "", "Notification provider (slack, msteams, generic) (required)")
+	cmd.Flags().StringVar(&secretRef, "secret-ref", "", "Name of the Secret holding the webhook address (required)")
+	cmd.Flags().StringVar(&severity, "severity", "error", "Minimum event severity to send (info, error)")
+	cmd.Flags().StringVar(&channel, "channel", "", "Channel to post to, for providers that support it")
+	cmd.Flags().StringVar(&address, "address", "", "Webhook address (defaults to $"+alertAddressEnv+")")
+	cmd.Flags().StringVar(&decryptionSecret, "decryption-secret", "sops-gpg", "Secret in the Flux namespace holding the SOPS decryption key")
+	cmd.Flags().StringVarP(&outDir, "out", "o", "", "Directory to write the manifests to (defaults to flux.path from the config)")
+	cmd.MarkFlagRequired("provider")
+	cmd.MarkFlagRequired("secret-ref")
+
+	return cmd
+}
+
+// addAlert writes the Provider and Alert manifests, the encrypted Secret and the Kustomization applying it
+func addAlert(environment, provider, secretRef, severity, channel, address, decryptionSecret, outDir string) {
+	if !alertProviders[provider] {
+		fmt.Printf("Unsupported alert provider: %s\n", provider)
+		return
+	}
+	if severity != "info" && severity != "error" {
+		fmt.Printf("Unsupported severity: %s\n", severity)
+		return
+	}
+	if address == "" {
+		fmt.Printf("Error: Set the webhook address with --address or %s\n", alertAddressEnv)
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	env, err := cfg.Environment(environment)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if outDir == "" {
+		outDir = cfg.Flux.Path
+	}
+
+	name := fmt.Sprintf("%s-%s", kustomizationName(cfg, env.Name), provider)
+	namespace := cfg.Flux.Namespace
+
+	// The Secret is encrypted before anything else is written
+	secret := Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   ObjectMeta{Name: secretRef, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Type:       "Opaque",
+		StringData: map[string]string{"address": address},
+	}
+	plaintext, err := manifest.Encode(secret)
+	if err != nil {
+		fmt.Println("Error encoding Secret:", err)
+		return
+	}
+	secretFile := filepath.Join(secrets.Dir(cfg.Secrets.Engine), secretRef+".yaml")
+	if err := secrets.WriteEncrypted(cfg.Secrets.Engine, secretFile, plaintext); err != nil {
+		fmt.Println("Error encrypting Secret:", err)
+		return
+	}
+	fmt.Printf("Wrote %s (encrypted with %s)\n", secretFile, cfg.Secrets.Engine)
+
+	notifyProvider := Provider{
+		APIVersion: NotifyAPIVersion,
+		Kind:       "Provider",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: ProviderSpec{
+			Type:      provider,
+			Channel:   channel,
+			SecretRef: &LocalObjectReference{Name: secretRef},
+		},
+	}
+	alert := Alert{
+		APIVersion: NotifyAPIVersion,
+		Kind:       "Alert",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: AlertSpec{
+			ProviderRef:   LocalObjectReference{Name: name},
+			EventSeverity: severity,
+			EventSources: []CrossNamespaceSourceReference{
+				{Kind: kustomizationResource.Kind, Name: kustomizationName(cfg, env.Name)},
+			},
+			EventMetadata: map[string]string{"app": cfg.App, "environment": env.Name},
+		},
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+	file := filepath.Join(outDir, name+"-alert.yaml")
+	if err := writeManifest(file, notifyProvider, alert); err != nil {
+		fmt.Println("Error writing alert manifests:", err)
+		return
+	}
+	secretsKustomization := newSecretsKustomization(cfg, decryptionSecret)
+	file = filepath.Join(outDir, secretsKustomization.Metadata.Name+".yaml")
+	if err := writeManifest(file, secretsKustomization); err != nil {
+		fmt.Println("Error writing secrets Kustomization:", err)
+		return
+	}
+
+	fmt.Println("Alerts generated successfully!")
+}
+
+// newSecretsKustomization builds the Kustomization applying the encrypted secrets of the project,
+// decrypting them with the key in decryptionSecret when they are encrypted with SOPS
+func newSecretsKustomization(cfg *config.Config, decryptionSecret string) Kustomization {
+	kustomization := Kustomization{
+		APIVersion: KustomizeAPIVersion,
+		Kind:       "Kustomization",
+		Metadata:   ObjectMeta{Name: cfg.App + "-secrets", Namespace: cfg.Flux.Namespace, Labels: managedLabels("")},
+		Spec: KustomizationSpec{
+			Interval: "10m0s",
+			Path:     "./" + filepath.ToSlash(secrets.Dir(cfg.Secrets.Engine)),
+			Prune:    true,
+			SourceRef: CrossNamespaceSourceReference{
+				Kind: "GitRepository",
+				Name: sourceName(cfg),
+			},
+		},
+	}
+	if cfg.Secrets.Engine == "sops" {
+		kustomization.Spec.Decryption = &Decryption{Provider: "sops", SecretRef: &LocalObjectReference{Name: decryptionSecret}}
+	}
+	return kustomization
+}
@@ -0,0 +1,39 @@
+package flux
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/secrets"
+)
+
+func TestNewSecretsKustomization(t *testing.T) {
+	tests := []struct {
+		engine  string
+		decrypt bool
+	}{
+		{"sops", true},
+		{"sealed-secrets", false},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{App: "demo", Flux: config.Flux{Namespace: "flux-system"}, Secrets: config.Secrets{Engine: tt.engine}}
+		k := newSecretsKustomization(cfg, "sops-gpg")
+
+		// The Secret written by alerts add must be applied by the Kustomization
+		file := filepath.ToSlash(filepath.Join(secrets.Dir(tt.engine), "webhook.yaml"))
+		if dir := path.Clean(k.Spec.Path); !strings.HasPrefix(file, dir+"/") {
+			t.Errorf("%s: Kustomization path %s does not cover %s", tt.engine, k.Spec.Path, file)
+		}
+		if k.Spec.SourceRef.Name != "demo-repo" {
+			t.Errorf("%s: source = %s, want demo-repo", tt.engine, k.Spec.SourceRef.Name)
+		}
+		if got := k.Spec.Decryption != nil; got != tt.decrypt {
+			t.Errorf("%s: decryption = %v, want %v", tt.engine, got, tt.decrypt)
+		} else if got && (k.Spec.Decryption.Provider != "sops" || k.Spec.Decryption.SecretRef.Name != "sops-gpg") {
+			t.Errorf("%s: decryption = %+v", tt.engine, *k.Spec.Decryption)
+		}
+	}
+}
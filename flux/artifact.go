@@ -0,0 +1,195 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kustomize"
+	"github.com/spf13/cobra"
+)
+
+// pushArtifactOptions holds the settings of the push-artifact command
+type pushArtifactOptions struct {
+	environment string
+	registry    string
+	tag         string
+	insecure    bool
+	generate    bool
+	outDir      string
+	replace     bool
+}
+
+// pushArtifactCmd creates a command to deliver an environment as an OCI artifact
+func pushArtifactCmd() *cobra.Command {
+	var opts pushArtifactOptions
+
+	cmd := &cobra.Command{
+		Use:   "push-artifact",
+		Short: "Push an environment's rendered manifests as an OCI artifact",
+		Long: `Render the Kustomize overlay of an environment and push it to an OCI registry as
+<registry>/<app>-<env>, tagged with the Git SHA and with the environment name. Clusters
+then pull the artifact instead of the Git repository.
+
+With --generate the OCIRepository and the <app>-<env>-oci Kustomization that follow the
+environment tag are written as well. The artifact holds the same objects as the overlay, so
+the Git Kustomization of the environment has to be removed first, or with --replace. Use --insecure for a plain HTTP registry, such as a local
+registry started with: docker run -d -p 5000:5000 registry:2`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pushArtifact(opts)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&opts.environment, "environment", "e", "dev", "Environment to render and push")
+	cmd.Flags().StringVarP(&opts.registry, "registry", "r", "", "OCI registry URL, e.g. oci://ghcr.io/org (required)")
+	cmd.Flags().StringVar(&opts.tag, "tag", "", "Artifact tag (defaults to the local Git SHA)")
+	cmd.Flags().BoolVar(&opts.insecure, "insecure", false, "Use plain HTTP to reach the registry")
+	cmd.Flags().BoolVar(&opts.generate, "generate", false, "Also generate the OCIRepository and Kustomization for the artifact")
+	cmd.Flags().StringVarP(&opts.outDir, "out", "o", "", "Directory to write the manifests to (defaults to flux.path from the config)")
+	cmd.Flags().BoolVar(&opts.replace, "replace", false, "With --generate, delete manifests in the output directory that reconcile the same overlay")
+	cmd.MarkFlagRequired("registry")
+
+	return cmd
+}
+
+// pushArtifact renders the overlay, pushes it and optionally generates the Flux objects pulling it
+func pushArtifact(opts pushArtifactOptions) {
+	if !strings.HasPrefix(opts.registry, "oci://") {
+		fmt.Println("Error: --registry must be an oci:// URL")
+		return
+	}
+	if _, err := exec.LookPath("flux"); err != nil {
+		fmt.Println("Error: Flux CLI is not installed. Please install it first.")
+		fmt.Println("Installation instructions: https://fluxcd.io/docs/installation/")
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	env, err := cfg.Environment(opts.environment)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if opts.outDir == "" {
+		opts.outDir = cfg.Flux.Path
+	}
+
+	// Check for Kustomizations reconciling the overlay before anything is pushed
+	url := strings.TrimSuffix(opts.registry, "/") + "/" + kustomizationName(cfg, env.Name)
+	repository, kustomization := newArtifactSource(cfg, env, url, opts.insecure)
+	if opts.generate {
+		source := generatedSource{File: kustomization.Metadata.Name + ".yaml", Kind: repository.Kind, Name: repository.Metadata.Name}
+		// The artifact is the rendered overlay, so it overlaps the Kustomizations reconciling the overlay itself
+		overlay := kustomization
+		overlay.Spec.Path = env.Overlay
+		if !clearOverlaps(opts.outDir, source, []Kustomization{overlay}, opts.replace) {
+			return
+		}
+	}
+
+	revision, err := localRevision()
+	if err != nil {
+		fmt.Println("Error determining the local Git revision:", err)
+		return
+	}
+	if opts.tag == "" {
+		opts.tag = revision
+	}
+	if out, err := exec.Command("git", "status", "--porcelain").Output(); err == nil && len(out) > 0 {
+		fmt.Printf("Warning: The working tree has uncommitted changes that are not part of revision %s\n", revision)
+	}
+
+	// Render the overlay into a directory of its own
+	fmt.Printf("Rendering overlay %s...\n", env.Overlay)
+	rendered, err := kustomize.Build(env.Overlay)
+	if err != nil {
+		fmt.Println("Error rendering overlay:", err)
+		return
+	}
+	dir, err := os.MkdirTemp("", "troyops-artifact-")
+	if err != nil {
+		fmt.Println("Error creating temporary directory:", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "manifests.yaml"), rendered, 0644); err != nil {
+		fmt.Println("Error writing rendered manifests:", err)
+		return
+	}
+
+	var insecure []string
+	if opts.insecure {
+		insecure = []string{"--insecure-registry"}
+	}
+
+	fmt.Printf("Pushing %s:%s...\n", url, opts.tag)
+	pushArgs := append([]string{"push", "artifact", url + ":" + opts.tag,
+		"--path", dir,
+		"--source", cfg.Repository.URL,
+		"--revision", fmt.Sprintf("%s@sha1:%s", cfg.Repository.Branch, revision),
+	}, insecure...)
+	pushCmd := exec.Command("flux", pushArgs...)
+	pushCmd.Stdout = os.Stdout
+	pushCmd.Stderr = os.Stderr
+	if err := pushCmd.Run(); err != nil {
+		fmt.Println("Error pushing artifact:", err)
+		return
+	}
+
+	// The environment tag is what the OCIRepository follows
+	tagArgs := append([]string{"tag", "artifact", url + ":" + opts.tag, "--tag", env.Name}, insecure...)
+	tagCmd := exec.Command("flux", tagArgs...)
+	tagCmd.Stdout = os.Stdout
+	tagCmd.Stderr = os.Stderr
+	if err := tagCmd.Run(); err != nil {
+		fmt.Println("Error tagging artifact:", err)
+		return
+	}
+
+	if opts.generate {
+		if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+			fmt.Println("Error creating output directory:", err)
+			return
+		}
+		file := filepath.Join(opts.outDir, kustomization.Metadata.Name+".yaml")
+		if err := writeManifest(file, repository, kustomization); err != nil {
+			fmt.Println("Error writing OCI manifests:", err)
+			return
+		}
+	}
+
+	fmt.Printf("Artifact %s:%s pushed successfully!\n", url, opts.tag)
+}
+
+// newArtifactSource builds the OCIRepository following an environment tag and the Kustomization applying it.
+// The Kustomization is named <app>-<env>-oci to tell it apart from the Git Kustomization of the environment.
+func newArtifactSource(cfg *config.Config, env *config.Environment, url string, insecure bool) (OCIRepository, Kustomization) {
+	name := kustomizationName(cfg, env.Name)
+	repository := OCIRepository{
+		APIVersion: SourceAPIVersion,
+		Kind:       "OCIRepository",
+		Metadata:   ObjectMeta{Name: name, Namespace: cfg.Flux.Namespace, Labels: managedLabels(env.Name)},
+		Spec: OCIRepositorySpec{
+			Interval: env.Interval,
+			URL:      url,
+			Ref:      &OCIRepositoryRef{Tag: env.Name},
+			Insecure: insecure,
+		},
+	}
+
+	// The artifact holds the rendered overlay at its root
+	kustomization := newKustomization(cfg, env)
+	kustomization.Metadata.Name = name + "-oci"
+	kustomization.Spec.Path = "./"
+	kustomization.Spec.SourceRef = CrossNamespaceSourceReference{Kind: "OCIRepository", Name: name}
+
+	return repository, kustomization
+}
@@ -0,0 +1,205 @@
+package flux
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// bootstrapOptions holds the settings of the Flux bootstrap
+type bootstrapOptions struct {
+	provider       string
+	repo           string
+	branch         string
+	namespace      string
+	path           string
+	owner          string
+	repository     string
+	hostname       string
+	personal       bool
+	readWriteKey   bool
+	privateKeyFile string
+	force          bool
+}
+
+// providerTokenEnv maps Git providers to the environment variable holding their API token
+var providerTokenEnv = map[string]string{
+	"github": "GITHUB_TOKEN",
+	"gitlab": "GITLAB_TOKEN",
+	"gitea":  "GITEA_TOKEN",
+}
+
+// providerHosts are the default hostnames of the Git providers
+var providerHosts = map[string]string{
+	"github": "github.com",
+	"gitlab": "gitlab.com",
+}
+
+// resolve fills in owner, repository and hostname from the repository URL and validates the options
+func (o *bootstrapOptions) resolve() error {
+	if o.provider == "generic" {
+		if o.repo == "" {
+			return fmt.Errorf("--repo is required for the generic provider")
+		}
+		// flux bootstrap git does not accept scp-style URLs
+		o.repo = sshURL(o.repo)
+		return nil
+	}
+	if _, ok := providerTokenEnv[o.provider]; !ok {
+		return fmt.Errorf("unsupported Git provider: %s", o.provider)
+	}
+
+	if o.repo != "" {
+		host, owner, repository, err := parseRepoURL(o.repo)
+		if err != nil {
+			return err
+		}
+		if o.owner == "" {
+			o.owner = owner
+		}
+		if o.repository == "" {
+			o.repository = repository
+		}
+		if o.hostname == "" && host != providerHosts[o.provider] {
+			o.hostname = host
+		}
+	}
+	if o.owner == "" || o.repository == "" {
+		return fmt.Errorf("--repo or --owner and --repository are required for the %s provider", o.provider)
+	}
+	if o.provider == "gitea" && o.hostname == "" {
+		return fmt.Errorf("--hostname is required for the gitea provider")
+	}
+	if os.Getenv(providerTokenEnv[o.provider]) == "" {
+		return fmt.Errorf("%s must be set to a %s token with permission to create deploy keys", providerTokenEnv[o.provider], o.provider)
+	}
+
+	// The repository URL is only used to compare with an existing installation
+	if o.repo == "" {
+		host := o.hostname
+		if host == "" {
+			host = providerHosts[o.provider]
+		}
+		o.repo = fmt.Sprintf("https://%s/%s/%s", host, o.owner, o.repository)
+	}
+	return nil
+}
+
+// bootstrapArgs returns the flux bootstrap arguments and a function removing temporary files
+func (o *bootstrapOptions) bootstrapArgs() ([]string, func(), error) {
+	cleanup := func() {}
+
+	if o.provider != "generic" {
+		// The provider API registers the deploy key flux generates
+		args := []string{"bootstrap", o.provider,
+			"--owner", o.owner,
+			"--repository", o.repository,
+			"--branch", o.branch,
+			"--path", o.path,
+			"--namespace", o.namespace,
+		}
+		if o.hostname != "" {
+			args = append(args, "--hostname", o.hostname)
+		}
+		if o.personal {
+			args = append(args, "--personal")
+		}
+		if o.readWriteKey {
+			args = append(args, "--read-write-key")
+		}
+		return args, cleanup, nil
+	}
+
+	args := []string{"bootstrap", "git",
+		"--url", o.repo,
+		"--branch", o.branch,
+		"--path", o.path,
+		"--namespace", o.namespace,
+	}
+	if !isSSHURL(o.repo) {
+		return args, cleanup, nil
+	}
+
+	// Generic SSH remotes need a deploy key registered by hand before bootstrapping
+	if o.privateKeyFile == "" {
+		dir, err := os.MkdirTemp("", "troyops-deploy-key-")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+
+		o.privateKeyFile = filepath.Join(dir, "identity")
+		keygenCmd := exec.Command("ssh-keygen", "-t", "ed25519", "-N", "", "-C", "flux-"+o.namespace, "-f", o.privateKeyFile)
+		if out, err := keygenCmd.CombinedOutput(); err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("ssh-keygen failed: %v: %s", err, out)
+		}
+		publicKey, err := os.ReadFile(o.privateKeyFile + ".pub")
+		if err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+
+		fmt.Println("Generated a deploy key for Flux. Add this public key to the repository:")
+		fmt.Println(strings.TrimSpace(string(publicKey)))
+		if !confirm("Has the deploy key been added?") {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("deploy key was not registered")
+		}
+	}
+	args = append(args, "--private-key-file", o.privateKeyFile, "--silent")
+	return args, cleanup, nil
+}
+
+// parseRepoURL splits an HTTPS or SSH repository URL into host, owner and repository.
+// The owner keeps nested groups, as used by GitLab.
+func parseRepoURL(repoURL string) (string, string, string, error) {
+	host, path := splitRepoURL(repoURL)
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if host == "" || i <= 0 {
+		return "", "", "", fmt.Errorf("cannot determine owner and repository from %s", repoURL)
+	}
+	return host, path[:i], path[i+1:], nil
+}
+
+// splitRepoURL returns the host and path of an HTTPS, ssh:// or scp-style repository URL
+func splitRepoURL(repoURL string) (string, string) {
+	if !strings.Contains(repoURL, "://") {
+		// scp-style: git@host:owner/repository.git
+		if at := strings.Index(repoURL, "@"); at >= 0 {
+			repoURL = repoURL[at+1:]
+		}
+		if colon := strings.Index(repoURL, ":"); colon >= 0 {
+			return repoURL[:colon], repoURL[colon+1:]
+		}
+		return "", repoURL
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", ""
+	}
+	return u.Hostname(), u.Path
+}
+
+// isSSHURL reports whether a repository URL is accessed over SSH
+func isSSHURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "ssh://") || (!strings.Contains(repoURL, "://") && strings.Contains(repoURL, "@"))
+}
+
+// sshURL converts an scp-style repository URL, git@host:owner/repository.git, to the
+// equivalent ssh://git@host/owner/repository.git. Other URLs are returned unchanged.
+func sshURL(repoURL string) string {
+	if strings.Contains(repoURL, "://") {
+		return repoURL
+	}
+	at := strings.Index(repoURL, "@")
+	colon := strings.Index(repoURL, ":")
+	if at < 0 || colon < at {
+		return repoURL
+	}
+	return "ssh://" + repoURL[:colon] + "/" + strings.TrimPrefix(repoURL[colon+1:], "/")
+}
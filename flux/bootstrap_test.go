@@ -0,0 +1,37 @@
+package flux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBootstrapGenericURL(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+		ssh  bool
+	}{
+		{"git@git.example.com:team/app.git", "ssh://git@git.example.com/team/app.git", true},
+		{"ssh://git@git.example.com/team/app.git", "ssh://git@git.example.com/team/app.git", true},
+		{"ssh://git@git.example.com:2222/team/app.git", "ssh://git@git.example.com:2222/team/app.git", true},
+		{"https://git.example.com/team/app.git", "https://git.example.com/team/app.git", false},
+	}
+	for _, tt := range tests {
+		o := &bootstrapOptions{provider: "generic", repo: tt.repo, branch: "main", namespace: "flux-system", path: "clusters/dev", privateKeyFile: "identity"}
+		if err := o.resolve(); err != nil {
+			t.Fatalf("%s: %v", tt.repo, err)
+		}
+		args, cleanup, err := o.bootstrapArgs()
+		cleanup()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.repo, err)
+		}
+		joined := strings.Join(args, " ")
+		if !strings.Contains(joined, "--url "+tt.want+" ") {
+			t.Errorf("%s: args = %s, want --url %s", tt.repo, joined, tt.want)
+		}
+		if got := strings.Contains(joined, "--private-key-file"); got != tt.ssh {
+			t.Errorf("%s: private key passed = %v, want %v", tt.repo, got, tt.ssh)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package flux
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+// installation describes an existing Flux installation
+type installation struct {
+	Version    string
+	Components map[string]string
+	URL        string
+	Branch     string
+	Path       string
+}
+
+// deployment is the subset of a Deployment needed to identify controller images
+type deployment struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     struct {
+		Template struct {
+			Spec struct {
+				Containers []struct {
+					Name  string `yaml:"name"`
+					Image string `yaml:"image"`
+				} `yaml:"containers"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// detectInstallation returns the Flux installation in the namespace, or nil when there is none
+func detectInstallation(namespace string) (*installation, error) {
+	var deployments []deployment
+	if err := kube.List("deployments", namespace, "app.kubernetes.io/part-of=flux", &deployments); err != nil {
+		return nil, err
+	}
+	if len(deployments) == 0 {
+		return nil, nil
+	}
+
+	inst := &installation{Components: map[string]string{}}
+	for _, d := range deployments {
+		if v := d.Metadata.Labels["app.kubernetes.io/version"]; v != "" {
+			inst.Version = v
+		}
+		if containers := d.Spec.Template.Spec.Containers; len(containers) > 0 {
+			inst.Components[d.Metadata.Name] = containers[0].Image
+		}
+	}
+
+	// Bootstrap names its GitRepository and Kustomization after the namespace
+	var repo GitRepository
+	if err := kube.Get(gitRepositoryResource.Resource, namespace, namespace, &repo); err == nil {
+		inst.URL = repo.Spec.URL
+		if repo.Spec.Ref != nil {
+			inst.Branch = repo.Spec.Ref.Branch
+		}
+	}
+	var sync Kustomization
+	if err := kube.Get(kustomizationResource.Resource, namespace, namespace, &sync); err == nil {
+		inst.Path = sync.Spec.Path
+	}
+
+	return inst, nil
+}
+
+// desiredComponents returns the controller images the installed flux CLI would deploy
+func desiredComponents(namespace string) (map[string]string, error) {
+	var stdout, stderr bytes.Buffer
+	exportCmd := exec.Command("flux", "install", "--export", "--namespace", namespace)
+	exportCmd.Stdout = &stdout
+	exportCmd.Stderr = &stderr
+	if err := exportCmd.Run(); err != nil {
+		return nil, fmt.Errorf("flux install --export failed: %v: %s", err, stderr.String())
+	}
+
+	objects, err := manifest.Decode(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	components := map[string]string{}
+	for _, obj := range objects {
+		if obj.Kind() != "Deployment" {
+			continue
+		}
+		spec, _ := obj["spec"].(map[string]interface{})
+		template, _ := spec["template"].(map[string]interface{})
+		podSpec, _ := template["spec"].(map[string]interface{})
+		containers, _ := podSpec["containers"].([]interface{})
+		if len(containers) == 0 {
+			continue
+		}
+		if container, ok := containers[0].(map[string]interface{}); ok {
+			image, _ := container["image"].(string)
+			components[obj.Name()] = image
+		}
+	}
+	return components, nil
+}
+
+// installationChanges lists the differences between an installation and the requested setup
+func installationChanges(inst *installation, desired map[string]string, gitRepo, gitBranch, path string) []string {
+	var changes []string
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		current, ok := inst.Components[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("install %s (%s)", name, desired[name]))
+		case current != desired[name]:
+			changes = append(changes, fmt.Sprintf("upgrade %s: %s -> %s", name, current, desired[name]))
+		}
+	}
+
+	if inst.URL == "" {
+		changes = append(changes, fmt.Sprintf("bootstrap from %s (branch: %s, path: %s)", gitRepo, gitBranch, path))
+		return changes
+	}
+	if normalizeURL(inst.URL) != normalizeURL(gitRepo) {
+		changes = append(changes, fmt.Sprintf("re-point repository: %s -> %s", inst.URL, gitRepo))
+	}
+	if inst.Branch != gitBranch {
+		changes = append(changes, fmt.Sprintf("re-point branch: %s -> %s", inst.Branch, gitBranch))
+	}
+	if normalizePath(inst.Path) != normalizePath(path) {
+		changes = append(changes, fmt.Sprintf("re-point path: %s -> %s", inst.Path, path))
+	}
+
+	return changes
+}
+
+// normalizeURL reduces a repository URL to host and path, so that the HTTPS and SSH
+// URLs of the same repository compare equal
+func normalizeURL(url string) string {
+	host, path := splitRepoURL(url)
+	return host + "/" + strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
+
+// normalizePath strips leading ./ and trailing / from a repository path
+func normalizePath(path string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path, "./"), "/")
+}
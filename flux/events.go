@@ -0,0 +1,249 @@
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ANSI colours used by the event stream
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorDim   = "\033[2m"
+)
+
+// Event is the subset of a Kubernetes Event shown by flux events
+type Event struct {
+	Metadata struct {
+		CreationTimestamp string `yaml:"creationTimestamp"`
+	} `yaml:"metadata"`
+	InvolvedObject struct {
+		Kind      string `yaml:"kind"`
+		Namespace string `yaml:"namespace"`
+		Name      string `yaml:"name"`
+	} `yaml:"involvedObject"`
+	Type               string `yaml:"type"`
+	Reason             string `yaml:"reason"`
+	Message            string `yaml:"message"`
+	LastTimestamp      string `yaml:"lastTimestamp"`
+	EventTime          string `yaml:"eventTime"`
+	ReportingComponent string `yaml:"reportingComponent"`
+	Source             struct {
+		Component string `yaml:"component"`
+	} `yaml:"source"`
+}
+
+// Time returns when the event last occurred
+func (e *Event) Time() time.Time {
+	for _, ts := range []string{e.LastTimestamp, e.EventTime, e.Metadata.CreationTimestamp} {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Controller returns the Flux controller that emitted the event
+func (e *Event) Controller() string {
+	if e.ReportingComponent != "" {
+		return e.ReportingComponent
+	}
+	return e.Source.Component
+}
+
+// Severity maps the event type to the Flux severity
+func (e *Event) Severity() string {
+	if e.Type == "Warning" {
+		return "error"
+	}
+	return "info"
+}
+
+// eventLine is the JSON form of an event written by flux events -o json
+type eventLine struct {
+	Time        string `json:"time"`
+	Environment string `json:"environment,omitempty"`
+	Severity    string `json:"severity"`
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Reason      string `json:"reason"`
+	Message     string `json:"message"`
+	Controller  string `json:"controller,omitempty"`
+}
+
+// eventsCmd creates a command to show the events of TroyOps-managed Flux objects
+func eventsCmd() *cobra.Command {
+	var environment string
+	var severity string
+	var namespace string
+	var output string
+	var watch bool
+	var noColor bool
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show events emitted by Flux for TroyOps objects",
+		Long: `Show the Kubernetes Events emitted by the Flux controllers for the objects generated
+by TroyOps, oldest first. With --watch new events are streamed until interrupted.
+
+Use -o json to write one JSON object per line for piping into other tools.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := showEvents(environment, severity, namespace, output, watch, noColor); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&environment, "environment", "e", "", "Only show events of this environment's objects")
+	cmd.Flags().StringVar(&severity, "severity", "info", "Minimum severity to show (info, error)")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace of the Flux objects (defaults to flux.namespace from the config)")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (text, json)")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Stream new events until interrupted")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable coloured output")
+
+	return cmd
+}
+
+// showEvents prints the events of the managed objects, following new ones when watching
+func showEvents(environment, severity, namespace, output string, watch, noColor bool) error {
+	if severity != "info" && severity != "error" {
+		return fmt.Errorf("unsupported severity: %s", severity)
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unsupported output format: %s", output)
+	}
+	if namespace == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		namespace = cfg.Flux.Namespace
+	}
+
+	// Collect the managed objects and their environments
+	selector := ManagedByLabel + "=" + ManagedByValue
+	if environment != "" {
+		selector += "," + EnvironmentLabel + "=" + environment
+	}
+	managed, err := listManaged(namespace, selector)
+	if err != nil {
+		return err
+	}
+	if len(managed) == 0 && !watch {
+		return fmt.Errorf("no TroyOps-managed Flux objects found in namespace %s", namespace)
+	}
+
+	color := !noColor && output == "text" && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	// Objects created while watching are picked up by listing again on their first event
+	unmanaged := map[string]bool{}
+	emit := func(e Event) error {
+		id := e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name
+		env, ok := managed[id]
+		if !ok && watch && !unmanaged[id] {
+			if managed, err = listManaged(namespace, selector); err != nil {
+				return err
+			}
+			env, ok = managed[id]
+			unmanaged[id] = !ok
+		}
+		if !ok || (severity == "error" && e.Severity() != "error") {
+			return nil
+		}
+		if output == "json" {
+			line, _ := json.Marshal(eventLine{
+				Time:        e.Time().Format(time.RFC3339),
+				Environment: env,
+				Severity:    e.Severity(),
+				Kind:        e.InvolvedObject.Kind,
+				Namespace:   e.InvolvedObject.Namespace,
+				Name:        e.InvolvedObject.Name,
+				Reason:      e.Reason,
+				Message:     e.Message,
+				Controller:  e.Controller(),
+			})
+			fmt.Println(string(line))
+			return nil
+		}
+		fmt.Println(formatEvent(e, env, color))
+		return nil
+	}
+
+	if !watch {
+		var events []Event
+		if err := kube.List("events", namespace, "", &events); err != nil {
+			return err
+		}
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Time().Before(events[j].Time()) })
+		for _, e := range events {
+			if err := emit(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return kube.Watch("events", namespace, func(raw []byte) error {
+		var e Event
+		if err := yaml.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		return emit(e)
+	})
+}
+
+// listManaged returns the environments of the Flux objects matching selector, keyed by Kind/name
+func listManaged(namespace, selector string) (map[string]string, error) {
+	managed := map[string]string{}
+	for _, res := range statusResources {
+		var objects []LiveObject
+		if err := kube.List(res.Resource, namespace, selector, &objects); err != nil {
+			if strings.Contains(err.Error(), "the server doesn't have a resource type") {
+				continue
+			}
+			return nil, err
+		}
+		for _, obj := range objects {
+			managed[res.Kind+"/"+obj.Metadata.Name] = obj.Metadata.Labels[EnvironmentLabel]
+		}
+	}
+	return managed, nil
+}
+
+// formatEvent renders an event as a compact line
+func formatEvent(e Event, environment string, color bool) string {
+	symbol, tint := "✔", colorGreen
+	if e.Severity() == "error" {
+		symbol, tint = "✖", colorRed
+	}
+	if environment == "" {
+		environment = "-"
+	}
+
+	timestamp := e.Time().Local().Format("15:04:05")
+	object := fmt.Sprintf("%s/%s", e.InvolvedObject.Kind, e.InvolvedObject.Name)
+	message := strings.ReplaceAll(strings.TrimSpace(e.Message), "\n", " ")
+	if !color {
+		return fmt.Sprintf("%s %s [%s] %s %s: %s", timestamp, symbol, environment, object, e.Reason, message)
+	}
+	return fmt.Sprintf("%s%s%s %s%s%s [%s] %s %s%s%s: %s", colorDim, timestamp, colorReset,
+		tint, symbol, colorReset, environment, object, tint, e.Reason, colorReset, message)
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -10,38 +10,69 @@ import (
 
 // SetupFluxCmd defines the Flux setup command
 func SetupFluxCmd() *cobra.Command {
-	var gitRepo string
-	var gitBranch string
-	var namespace string
-	var path string
+	var opts bootstrapOptions
 
 	cmd := &cobra.Command{
 		Use:   "flux",
 		Short: "Setup Flux CD for GitOps",
-		Long:  `Setup and configure Flux CD for GitOps-driven deployments.`,
+		Long: `Setup and configure Flux CD for GitOps-driven deployments.
+
+With --provider github, gitlab or gitea the repository is bootstrapped through the
+provider API, which registers the deploy key; the token is read from GITHUB_TOKEN,
+GITLAB_TOKEN or GITEA_TOKEN. The generic provider bootstraps any Git URL, such as a
+bare repository served over SSH, and generates a deploy key to add by hand.
+
+An existing Flux installation in the namespace is detected and only upgraded or
+re-pointed when its component versions or bootstrap source differ from the request.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			setupFlux(gitRepo, gitBranch, namespace, path)
+			setupFlux(opts)
 		},
 	}
 
 	// Add flags
-	cmd.Flags().StringVarP(&gitRepo, "repo", "r", "", "Git repository URL (required)")
-	cmd.Flags().StringVarP(&gitBranch, "branch", "b", "main", "Git branch to use")
-	cmd.Flags().StringVarP(&namespace, "namespace", "n", "flux-system", "Kubernetes namespace for Flux")
-	cmd.Flags().StringVarP(&path, "path", "p", "./flux", "Path to Flux manifests in the repository")
-	cmd.MarkFlagRequired("repo")
+	cmd.Flags().StringVarP(&opts.repo, "repo", "r", "", "Git repository URL (required for the generic provider)")
+	cmd.Flags().StringVarP(&opts.branch, "branch", "b", "main", "Git branch to use")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "flux-system", "Kubernetes namespace for Flux")
+	cmd.Flags().StringVarP(&opts.path, "path", "p", "./flux", "Path to Flux manifests in the repository")
+	cmd.Flags().StringVar(&opts.provider, "provider", "generic", "Git provider (github, gitlab, gitea, generic)")
+	cmd.Flags().StringVar(&opts.owner, "owner", "", "Repository owner, user or group (defaults to the one in --repo)")
+	cmd.Flags().StringVar(&opts.repository, "repository", "", "Repository name (defaults to the one in --repo)")
+	cmd.Flags().StringVar(&opts.hostname, "hostname", "", "Hostname of a self-hosted provider")
+	cmd.Flags().BoolVar(&opts.personal, "personal", false, "The owner is a user rather than an organization or group")
+	cmd.Flags().BoolVar(&opts.readWriteKey, "read-write-key", false, "Give the deploy key write access, as needed by image automation")
+	cmd.Flags().StringVar(&opts.privateKeyFile, "private-key-file", "", "Existing SSH deploy key for the generic provider")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Re-run bootstrap even when the installation is up to date")
 
 	// Add subcommands
 	cmd.AddCommand(syncCmd())
 	cmd.AddCommand(checkCmd())
+	cmd.AddCommand(generateCmd())
+	cmd.AddCommand(statusCmd())
+	cmd.AddCommand(suspendCmd())
+	cmd.AddCommand(resumeCmd())
+	cmd.AddCommand(suspendedCmd())
+	cmd.AddCommand(uninstallCmd())
+	cmd.AddCommand(imageAutomationCmd())
+	cmd.AddCommand(alertsCmd())
+	cmd.AddCommand(helmReleaseCmd())
+	cmd.AddCommand(pushArtifactCmd())
+	cmd.AddCommand(eventsCmd())
+	cmd.AddCommand(graphCmd())
+	cmd.AddCommand(tenantCmd())
 
 	return cmd
 }
 
-// setupFlux installs and configures Flux CD
-func setupFlux(gitRepo, gitBranch, namespace, path string) {
+// setupFlux installs and configures Flux CD, or brings an existing installation in line with the request
+func setupFlux(opts bootstrapOptions) {
 	fmt.Println("Setting up Flux CD...")
 
+	if err := opts.resolve(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	namespace := opts.namespace
+
 	// Check if flux CLI is installed
 	_, err := exec.LookPath("flux")
 	if err != nil {
@@ -50,25 +81,52 @@ func setupFlux(gitRepo, gitBranch, namespace, path string) {
 		return
 	}
 
-	// Install Flux components
-	fmt.Println("Installing Flux components...")
-	installCmd := exec.Command("flux", "install", "--namespace", namespace)
-	installCmd.Stdout = os.Stdout
-	installCmd.Stderr = os.Stderr
-	if err := installCmd.Run(); err != nil {
-		fmt.Println("Error installing Flux:", err)
+	// Check for an existing installation
+	existing, err := detectInstallation(namespace)
+	if err != nil {
+		fmt.Println("Error detecting existing Flux installation:", err)
 		return
 	}
 
-	// Bootstrap Flux with the Git repository
-	fmt.Printf("Bootstrapping Flux with repository %s (branch: %s)...\n", gitRepo, gitBranch)
-	bootstrapCmd := exec.Command(
-		"flux", "bootstrap", "git",
-		"--url", gitRepo,
-		"--branch", gitBranch,
-		"--path", path,
-		"--namespace", namespace,
-	)
+	if existing == nil {
+		// Install Flux components
+		fmt.Println("Installing Flux components...")
+		installCmd := exec.Command("flux", "install", "--namespace", namespace)
+		installCmd.Stdout = os.Stdout
+		installCmd.Stderr = os.Stderr
+		if err := installCmd.Run(); err != nil {
+			fmt.Println("Error installing Flux:", err)
+			return
+		}
+	} else {
+		fmt.Printf("Found existing Flux %s installation in namespace %s\n", existing.Version, namespace)
+
+		desired, err := desiredComponents(namespace)
+		if err != nil {
+			fmt.Println("Error determining Flux component versions:", err)
+			return
+		}
+
+		changes := installationChanges(existing, desired, opts.repo, opts.branch, opts.path)
+		if len(changes) == 0 && !opts.force {
+			fmt.Println("Flux is already up to date, nothing to do.")
+			return
+		}
+		for _, change := range changes {
+			fmt.Println("  -", change)
+		}
+	}
+
+	// Bootstrap Flux with the Git repository. Bootstrap also upgrades the components
+	// committed to the repository, so a plain install would be reverted by the sync.
+	args, cleanup, err := opts.bootstrapArgs()
+	defer cleanup()
+	if err != nil {
+		fmt.Println("Error preparing bootstrap:", err)
+		return
+	}
+	fmt.Printf("Bootstrapping Flux with repository %s (branch: %s)...\n", opts.repo, opts.branch)
+	bootstrapCmd := exec.Command("flux", args...)
 	bootstrapCmd.Stdout = os.Stdout
 	bootstrapCmd.Stderr = os.Stderr
 	if err := bootstrapCmd.Run(); err != nil {
@@ -79,25 +137,6 @@ func setupFlux(gitRepo, gitBranch, namespace, path string) {
 	fmt.Println("Flux CD setup completed successfully!")
 }
 
-// syncCmd creates a command to manually trigger Flux synchronization
-func syncCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "sync",
-		Short: "Trigger Flux synchronization",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Triggering Flux synchronization...")
-			syncCmd := exec.Command("flux", "reconcile", "source", "git", "--all")
-			syncCmd.Stdout = os.Stdout
-			syncCmd.Stderr = os.Stderr
-			if err := syncCmd.Run(); err != nil {
-				fmt.Println("Error triggering synchronization:", err)
-				return
-			}
-			fmt.Println("Flux synchronization triggered successfully!")
-		},
-	}
-}
-
 // checkCmd creates a command to check Flux status
 func checkCmd() *cobra.Command {
 	return &cobra.Command{
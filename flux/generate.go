@@ -0,0 +1,304 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// generatedHeader is written at the top of every generated manifest
+const generatedHeader = "# Generated by troyops from %s. Changes are overwritten on regeneration.\n"
+
+// defaultSourceInterval is how often generated GitRepositories are fetched for new commits
+const defaultSourceInterval = "1m0s"
+
+// generateCmd creates a command to generate Flux manifests from the project config
+func generateCmd() *cobra.Command {
+	var environment string
+	var outDir string
+	var replace bool
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate Flux manifests from the project config",
+		Long: `Generate the GitRepository source and one Kustomization per environment from the
+project config (troyops.yaml), so that the Flux configuration is reproducible and
+reviewable in Git.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			generateManifests(environment, outDir, replace)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&environment, "environment", "e", "", "Only generate the Kustomization for this environment")
+	cmd.Flags().StringVarP(&outDir, "out", "o", "", "Directory to write the manifests to (defaults to flux.path from the config)")
+	cmd.Flags().BoolVar(&replace, "replace", false, "Delete hand-written manifests in the output directory that reconcile the same overlays")
+
+	return cmd
+}
+
+// generateManifests writes the GitRepository and Kustomization manifests
+func generateManifests(environment, outDir string, replace bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	if cfg.Repository.URL == "" {
+		fmt.Printf("Error: repository.url must be set in %s\n", config.File)
+		return
+	}
+	if outDir == "" {
+		outDir = cfg.Flux.Path
+	}
+
+	environments := cfg.Environments
+	if environment != "" {
+		env, err := cfg.Environment(environment)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		environments = []config.Environment{*env}
+	}
+	if len(environments) == 0 {
+		fmt.Printf("Error: No environments defined in %s or found in kustomize/overlays\n", config.File)
+		return
+	}
+
+	// Two Kustomizations reconciling the same overlay fight over its objects
+	var kustomizations []Kustomization
+	for i := range environments {
+		kustomizations = append(kustomizations, newKustomization(cfg, &environments[i]))
+	}
+	repo := newGitRepository(cfg)
+	source := generatedSource{File: "git-repository.yaml", Kind: repo.Kind, Name: repo.Metadata.Name}
+	if !clearOverlaps(outDir, source, kustomizations, replace) {
+		return
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+
+	fmt.Printf("Generating Flux manifests in %s...\n", outDir)
+	if err := writeManifest(filepath.Join(outDir, source.File), repo); err != nil {
+		fmt.Println("Error writing GitRepository:", err)
+		return
+	}
+	for i := range environments {
+		env := &environments[i]
+		for _, dep := range env.DependsOn {
+			if _, err := cfg.Environment(dep); err != nil {
+				fmt.Printf("Warning: %s depends on unknown environment '%s'\n", env.Name, dep)
+			}
+		}
+		file := filepath.Join(outDir, kustomizationName(cfg, env.Name)+".yaml")
+		if err := writeManifest(file, kustomizations[i]); err != nil {
+			fmt.Printf("Error writing Kustomization for %s: %v\n", env.Name, err)
+			return
+		}
+	}
+
+	fmt.Println("Flux manifests generated successfully!")
+}
+
+// writeManifest encodes the objects to file with the generated header
+func writeManifest(file string, objects ...interface{}) error {
+	data, err := manifest.Encode(objects...)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf(generatedHeader, config.File)
+	if err := os.WriteFile(file, append([]byte(header), data...), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", file)
+	return nil
+}
+
+// clearOverlaps reports the manifests in dir overlapping with the generated ones and, with replace,
+// deletes the files holding only overlapping objects. It returns false when generation must stop.
+func clearOverlaps(dir string, source generatedSource, kustomizations []Kustomization, replace bool) bool {
+	overlaps, err := findOverlaps(dir, source, kustomizations)
+	if err != nil {
+		fmt.Println("Error reading existing manifests:", err)
+		return false
+	}
+	for _, o := range overlaps {
+		fmt.Printf("%s: %s\n", o.File, o.Reason)
+	}
+	for _, o := range overlaps {
+		if !replace || !o.Whole {
+			fmt.Println("Error: existing manifests overlap with the generated ones; remove them, or rerun with --replace to delete files holding only overlapping objects")
+			return false
+		}
+	}
+	for _, o := range overlaps {
+		if err := os.Remove(o.File); err != nil {
+			fmt.Println("Error:", err)
+			return false
+		}
+		fmt.Printf("Deleted %s\n", o.File)
+	}
+	return true
+}
+
+// generatedSource is the source object written next to the generated Kustomizations
+type generatedSource struct {
+	File string
+	Kind string
+	Name string
+}
+
+// overlap is a manifest in the output directory, not written by troyops, whose objects
+// collide with the generated ones
+type overlap struct {
+	File   string
+	Reason string
+	// Whole is set when every object of the file collides, so that it can be deleted
+	Whole bool
+}
+
+// findOverlaps looks for manifests in dir that the generated source and Kustomizations would be
+// applied next to, and that define the same objects or reconcile the same overlay paths.
+// Files the generation overwrites are not reported.
+func findOverlaps(dir string, source generatedSource, kustomizations []Kustomization) ([]overlap, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	generated := map[string]bool{source.File: true}
+	names := map[string]bool{}
+	paths := map[string]string{}
+	for _, k := range kustomizations {
+		generated[k.Metadata.Name+".yaml"] = true
+		names[k.Metadata.Name] = true
+		paths[path.Clean(k.Spec.Path)] = k.Metadata.Name
+	}
+
+	var overlaps []overlap
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || generated[name] || !(strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := manifest.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		var reasons []string
+		for _, obj := range objects {
+			switch {
+			case obj.Kind() == source.Kind && obj.Name() == source.Name:
+				reasons = append(reasons, fmt.Sprintf("defines %s/%s, which troyops generates in %s", source.Kind, obj.Name(), source.File))
+			case obj.Kind() == "Kustomization" && names[obj.Name()]:
+				reasons = append(reasons, fmt.Sprintf("defines Kustomization/%s, which troyops generates in %s.yaml", obj.Name(), obj.Name()))
+			case obj.Kind() == "Kustomization":
+				spec, _ := obj["spec"].(map[string]interface{})
+				overlay, _ := spec["path"].(string)
+				if owner, ok := paths[path.Clean(overlay)]; ok && overlay != "" {
+					reasons = append(reasons, fmt.Sprintf("Kustomization/%s reconciles %s, like the generated Kustomization/%s", obj.Name(), overlay, owner))
+				}
+			}
+		}
+		if len(reasons) > 0 {
+			overlaps = append(overlaps, overlap{File: file, Reason: strings.Join(reasons, "; "), Whole: len(reasons) == len(objects)})
+		}
+	}
+	return overlaps, nil
+}
+
+// sourceName returns the name of the GitRepository generated for the project
+func sourceName(cfg *config.Config) string {
+	return cfg.App + "-repo"
+}
+
+// kustomizationName returns the name of the Kustomization generated for an environment
+func kustomizationName(cfg *config.Config, environment string) string {
+	return fmt.Sprintf("%s-%s", cfg.App, environment)
+}
+
+// managedLabels returns the labels set on generated objects
+func managedLabels(environment string) map[string]string {
+	labels := map[string]string{ManagedByLabel: ManagedByValue}
+	if environment != "" {
+		labels[EnvironmentLabel] = environment
+	}
+	return labels
+}
+
+// newGitRepository builds the GitRepository source for the project repository
+func newGitRepository(cfg *config.Config) GitRepository {
+	repo := GitRepository{
+		APIVersion: SourceAPIVersion,
+		Kind:       "GitRepository",
+		Metadata: ObjectMeta{
+			Name:      sourceName(cfg),
+			Namespace: cfg.Flux.Namespace,
+			Labels:    managedLabels(""),
+		},
+		Spec: GitRepositorySpec{
+			Interval: defaultSourceInterval,
+			URL:      cfg.Repository.URL,
+			Ref:      &GitRepositoryRef{Branch: cfg.Repository.Branch},
+		},
+	}
+	if cfg.Repository.SecretRef != "" {
+		repo.Spec.SecretRef = &LocalObjectReference{Name: cfg.Repository.SecretRef}
+	}
+	return repo
+}
+
+// newKustomization builds the Kustomization applying an environment's overlay
+func newKustomization(cfg *config.Config, env *config.Environment) Kustomization {
+	kustomization := Kustomization{
+		APIVersion: KustomizeAPIVersion,
+		Kind:       "Kustomization",
+		Metadata: ObjectMeta{
+			Name:      kustomizationName(cfg, env.Name),
+			Namespace: cfg.Flux.Namespace,
+			Labels:    managedLabels(env.Name),
+		},
+		Spec: KustomizationSpec{
+			Interval: env.Interval,
+			Timeout:  env.Timeout,
+			Path:     env.Overlay,
+			Prune:    env.PruneEnabled(),
+			SourceRef: CrossNamespaceSourceReference{
+				Kind: "GitRepository",
+				Name: sourceName(cfg),
+			},
+		},
+	}
+
+	for _, dep := range env.DependsOn {
+		kustomization.Spec.DependsOn = append(kustomization.Spec.DependsOn, DependencyReference{Name: kustomizationName(cfg, dep)})
+	}
+	for _, check := range env.HealthChecks {
+		ref := NamespacedObjectKindReference(check)
+		if ref.Namespace == "" {
+			ref.Namespace = env.Namespace
+		}
+		kustomization.Spec.HealthChecks = append(kustomization.Spec.HealthChecks, ref)
+	}
+
+	return kustomization
+}
@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// Overwritten by the generation
+		"git-repository.yaml": "kind: GitRepository\nmetadata:\n  name: demo-repo\n",
+		"troyops-app.yaml":    "kind: Kustomization\nmetadata:\n  name: demo-app\nspec:\n  path: ./kustomize/overlays/dev\n",
+		"mixed.yaml":          "kind: Kustomization\nmetadata:\n  name: demo-prod\nspec:\n  path: ./other\n---\nkind: ConfigMap\nmetadata:\n  name: settings\n",
+		"unrelated.yaml":      "kind: Kustomization\nmetadata:\n  name: monitoring\nspec:\n  path: ./monitoring\n",
+		"readme.md":           "kind: Kustomization\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := generatedSource{File: "git-repository.yaml", Kind: "GitRepository", Name: "demo-repo"}
+	kustomizations := []Kustomization{
+		{Metadata: ObjectMeta{Name: "demo-dev"}, Spec: KustomizationSpec{Path: "kustomize/overlays/dev"}},
+		{Metadata: ObjectMeta{Name: "demo-prod"}, Spec: KustomizationSpec{Path: "./kustomize/overlays/prod"}},
+	}
+	overlaps, err := findOverlaps(dir, source, kustomizations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file  string
+		whole bool
+	}{
+		{"mixed.yaml", false},
+		{"troyops-app.yaml", true},
+	}
+	if len(overlaps) != len(tests) {
+		t.Fatalf("got %d overlaps, want %d: %+v", len(overlaps), len(tests), overlaps)
+	}
+	for i, tt := range tests {
+		if got := overlaps[i]; got.File != filepath.Join(dir, tt.file) || got.Whole != tt.whole {
+			t.Errorf("overlap %d = %+v, want %s with whole=%v", i, got, tt.file, tt.whole)
+		}
+	}
+
+	if overlaps, err := findOverlaps(filepath.Join(dir, "missing"), source, kustomizations); err != nil || overlaps != nil {
+		t.Errorf("missing directory: overlaps=%v, err=%v", overlaps, err)
+	}
+}
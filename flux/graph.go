@@ -0,0 +1,499 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// graphNode is a Flux object in the dependency graph
+type graphNode struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Source    string
+	DependsOn []string
+	Origin    string
+}
+
+// Label returns the Kind/name shown for the node
+func (n *graphNode) Label() string {
+	return n.Kind + "/" + n.Name
+}
+
+// IsSource reports whether the node is a Flux source
+func (n *graphNode) IsSource() bool {
+	return strings.HasSuffix(n.Kind, "Repository")
+}
+
+// originObject is a decoded object and where it was read from
+type originObject struct {
+	obj    manifest.Object
+	origin string
+}
+
+// fluxGraph is the source and dependsOn graph of Flux objects keyed by Kind/namespace/name
+type fluxGraph struct {
+	Nodes   map[string]*graphNode
+	Missing []string
+	Cycles  [][]string
+}
+
+// graphCmd creates a command to visualise the Flux dependency graph
+func graphCmd() *cobra.Command {
+	var dir string
+	var live bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show the Flux source and dependency graph",
+		Long: `Build the graph of sources, Kustomizations and HelmReleases with their dependsOn
+relations from the manifests in the Flux directory, and optionally from the objects
+in the cluster, and print it as an ASCII tree, a Graphviz DOT or a Mermaid diagram.
+
+References to missing sources or dependencies and dependsOn cycles are reported and
+make the command exit with status 1, since they leave reconciliations stuck.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !showGraph(dir, live, output) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Directory with Flux manifests (defaults to flux.path from the config)")
+	cmd.Flags().BoolVar(&live, "live", false, "Include the Flux objects in the cluster")
+	cmd.Flags().StringVarP(&output, "output", "o", "tree", "Output format (tree, dot, mermaid)")
+
+	return cmd
+}
+
+// showGraph builds and prints the graph and returns false when it has problems
+func showGraph(dir string, live bool, output string) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return false
+	}
+	if dir == "" {
+		dir = cfg.Flux.Path
+	}
+
+	objects, err := readManifests(dir)
+	if err != nil {
+		fmt.Println("Error reading Flux manifests:", err)
+		return false
+	}
+	if live {
+		liveObjects, err := listLiveObjects(cfg.Flux.Namespace)
+		if err != nil {
+			fmt.Println("Error listing Flux objects:", err)
+			return false
+		}
+		objects = append(objects, liveObjects...)
+	}
+
+	graph := buildGraph(objects, cfg.Flux.Namespace)
+	if len(graph.Nodes) == 0 {
+		fmt.Printf("No Flux objects found in %s\n", dir)
+		return true
+	}
+
+	switch output {
+	case "tree":
+		fmt.Print(graph.Tree())
+	case "dot":
+		fmt.Print(graph.DOT())
+	case "mermaid":
+		fmt.Print(graph.Mermaid())
+	default:
+		fmt.Printf("Unsupported output format: %s\n", output)
+		return false
+	}
+
+	for _, missing := range graph.Missing {
+		fmt.Fprintln(os.Stderr, "Error: missing reference:", missing)
+	}
+	for _, cycle := range graph.Cycles {
+		fmt.Fprintln(os.Stderr, "Error: dependsOn cycle:", strings.Join(cycle, " -> "))
+	}
+	return len(graph.Missing) == 0 && len(graph.Cycles) == 0
+}
+
+// readManifests decodes the Flux objects in the YAML files of a directory tree
+func readManifests(dir string) ([]originObject, error) {
+	var objects []originObject
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		decoded, err := manifest.Decode(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %s: %v\n", path, err)
+			return nil
+		}
+		for _, obj := range decoded {
+			objects = append(objects, originObject{obj, path})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+// listLiveObjects lists the Flux objects of the graph kinds in the cluster
+func listLiveObjects(namespace string) ([]originObject, error) {
+	var objects []originObject
+	for _, res := range statusResources {
+		var items []map[string]interface{}
+		if err := kube.List(res.Resource, namespace, "", &items); err != nil {
+			if strings.Contains(err.Error(), "the server doesn't have a resource type") {
+				continue
+			}
+			return nil, err
+		}
+		for _, item := range items {
+			obj := manifest.Object(item)
+			obj["kind"] = res.Kind
+			objects = append(objects, originObject{obj, "cluster"})
+		}
+	}
+	return objects, nil
+}
+
+// isGraphObject reports whether obj is a Flux object shown in the dependency graph
+func isGraphObject(obj manifest.Object) bool {
+	for _, res := range statusResources {
+		if res.matches(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildGraph collects the Flux objects and checks their references
+func buildGraph(objects []originObject, defaultNamespace string) *fluxGraph {
+	graph := &fluxGraph{Nodes: map[string]*graphNode{}}
+
+	for _, o := range objects {
+		obj := o.obj
+		if !isGraphObject(obj) {
+			continue
+		}
+		namespace := obj.Namespace()
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		node := &graphNode{Kind: obj.Kind(), Namespace: namespace, Name: obj.Name(), Origin: o.origin}
+
+		// HelmReleases reference their source through the chart template or chartRef
+		ref, _ := dig(obj, "spec", "sourceRef").(map[string]interface{})
+		if ref == nil {
+			ref, _ = dig(obj, "spec", "chart", "spec", "sourceRef").(map[string]interface{})
+		}
+		if ref == nil {
+			ref, _ = dig(obj, "spec", "chartRef").(map[string]interface{})
+		}
+		if ref != nil {
+			node.Source = referenceKey(ref, fmt.Sprint(ref["kind"]), namespace)
+		}
+
+		deps, _ := dig(obj, "spec", "dependsOn").([]interface{})
+		for _, d := range deps {
+			if dep, ok := d.(map[string]interface{}); ok {
+				node.DependsOn = append(node.DependsOn, referenceKey(dep, node.Kind, namespace))
+			}
+		}
+
+		// Manifests in the repository take precedence over their live counterparts
+		key := nodeKey(node.Kind, node.Namespace, node.Name)
+		if existing, ok := graph.Nodes[key]; ok && existing.Origin != "cluster" {
+			continue
+		}
+		graph.Nodes[key] = node
+	}
+
+	for _, key := range graph.keys() {
+		node := graph.Nodes[key]
+		if node.Source != "" && graph.Nodes[node.Source] == nil {
+			graph.Missing = append(graph.Missing, fmt.Sprintf("%s uses source %s", node.Label(), displayKey(node.Source)))
+		}
+		for _, dep := range node.DependsOn {
+			if graph.Nodes[dep] == nil {
+				graph.Missing = append(graph.Missing, fmt.Sprintf("%s depends on %s", node.Label(), displayKey(dep)))
+			}
+		}
+	}
+	graph.Cycles = graph.findCycles()
+
+	return graph
+}
+
+// findCycles returns the dependsOn cycles, each starting and ending at the same object
+func (g *fluxGraph) findCycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var cycles [][]string
+
+	var visit func(key string)
+	visit = func(key string) {
+		state[key] = visiting
+		stack = append(stack, key)
+		for _, dep := range g.Nodes[key].DependsOn {
+			if g.Nodes[dep] == nil {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				var cycle []string
+				for i := len(stack) - 1; i >= 0; i-- {
+					cycle = append([]string{g.Nodes[stack[i]].Label()}, cycle...)
+					if stack[i] == dep {
+						break
+					}
+				}
+				cycles = append(cycles, append(cycle, g.Nodes[dep].Label()))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[key] = done
+	}
+
+	for _, key := range g.keys() {
+		if state[key] == unvisited {
+			visit(key)
+		}
+	}
+	return cycles
+}
+
+// Tree renders the graph with sources at the top, their consumers below them and
+// dependents nested under the objects they depend on
+func (g *fluxGraph) Tree() string {
+	dependents := map[string][]string{}
+	consumers := map[string][]string{}
+	var orphans []string
+	for _, key := range g.keys() {
+		node := g.Nodes[key]
+		if node.IsSource() {
+			continue
+		}
+		resolved := 0
+		for _, dep := range node.DependsOn {
+			if g.Nodes[dep] != nil {
+				dependents[dep] = append(dependents[dep], key)
+				resolved++
+			}
+		}
+		if resolved > 0 {
+			continue
+		}
+		if g.Nodes[node.Source] != nil {
+			consumers[node.Source] = append(consumers[node.Source], key)
+		} else {
+			orphans = append(orphans, key)
+		}
+	}
+
+	var b strings.Builder
+	var walk func(key, prefix string, last bool, path map[string]bool)
+	walk = func(key, prefix string, last bool, path map[string]bool) {
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+		node := g.Nodes[key]
+		label := node.Label()
+		if others := len(node.DependsOn); others > 1 {
+			label += fmt.Sprintf(" (depends on %d objects)", others)
+		}
+		if path[key] {
+			fmt.Fprintf(&b, "%s%s%s (cycle)\n", prefix, branch, label)
+			return
+		}
+		fmt.Fprintf(&b, "%s%s%s\n", prefix, branch, label)
+		path[key] = true
+		children := dependents[key]
+		for i, child := range children {
+			walk(child, prefix+indent, i == len(children)-1, path)
+		}
+		delete(path, key)
+	}
+
+	for _, key := range g.keys() {
+		if !g.Nodes[key].IsSource() {
+			continue
+		}
+		fmt.Fprintln(&b, g.Nodes[key].Label())
+		children := consumers[key]
+		for i, child := range children {
+			walk(child, "", i == len(children)-1, map[string]bool{})
+		}
+	}
+	if len(orphans) > 0 {
+		fmt.Fprintln(&b, "(no source)")
+		for i, key := range orphans {
+			walk(key, "", i == len(orphans)-1, map[string]bool{})
+		}
+	}
+	return b.String()
+}
+
+// DOT renders the graph in Graphviz format; sources point at their consumers and
+// dependencies at their dependents
+func (g *fluxGraph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "digraph flux {")
+	fmt.Fprintln(&b, "  rankdir=LR;")
+	for _, key := range g.keys() {
+		node := g.Nodes[key]
+		shape := "ellipse"
+		if node.IsSource() {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%s];\n", key, node.Label(), shape)
+	}
+	for _, key := range g.missingKeys() {
+		fmt.Fprintf(&b, "  %q [label=%q, color=red, style=dashed];\n", key, displayKey(key)+" (missing)")
+	}
+	for _, key := range g.keys() {
+		node := g.Nodes[key]
+		if node.Source != "" {
+			fmt.Fprintf(&b, "  %q -> %q;\n", node.Source, key)
+		}
+		for _, dep := range node.DependsOn {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, label=\"dependsOn\"];\n", dep, key)
+		}
+	}
+	fmt.Fprintln(&b, "}")
+	return b.String()
+}
+
+// mermaidID matches the characters that are not allowed in Mermaid node IDs
+var mermaidID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *fluxGraph) Mermaid() string {
+	id := func(key string) string { return mermaidID.ReplaceAllString(key, "_") }
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "graph LR")
+	for _, key := range g.keys() {
+		node := g.Nodes[key]
+		if node.IsSource() {
+			fmt.Fprintf(&b, "  %s[%q]\n", id(key), node.Label())
+		} else {
+			fmt.Fprintf(&b, "  %s(%q)\n", id(key), node.Label())
+		}
+	}
+	for _, key := range g.missingKeys() {
+		fmt.Fprintf(&b, "  %s{{%q}}\n", id(key), displayKey(key)+" (missing)")
+	}
+	for _, key := range g.keys() {
+		node := g.Nodes[key]
+		if node.Source != "" {
+			fmt.Fprintf(&b, "  %s --> %s\n", id(node.Source), id(key))
+		}
+		for _, dep := range node.DependsOn {
+			fmt.Fprintf(&b, "  %s -. dependsOn .-> %s\n", id(dep), id(key))
+		}
+	}
+	return b.String()
+}
+
+// keys returns the node keys with sources first, in a stable order
+func (g *fluxGraph) keys() []string {
+	keys := make([]string, 0, len(g.Nodes))
+	for key := range g.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := g.Nodes[keys[i]], g.Nodes[keys[j]]
+		if a.IsSource() != b.IsSource() {
+			return a.IsSource()
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// missingKeys returns the referenced keys that have no node
+func (g *fluxGraph) missingKeys() []string {
+	set := map[string]bool{}
+	for _, node := range g.Nodes {
+		for _, ref := range append([]string{node.Source}, node.DependsOn...) {
+			if ref != "" && g.Nodes[ref] == nil {
+				set[ref] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// referenceKey returns the node key of a reference, defaulting its kind and namespace
+func referenceKey(ref map[string]interface{}, kind, namespace string) string {
+	if k, ok := ref["kind"].(string); ok && k != "" {
+		kind = k
+	}
+	if ns, ok := ref["namespace"].(string); ok && ns != "" {
+		namespace = ns
+	}
+	name, _ := ref["name"].(string)
+	return nodeKey(kind, namespace, name)
+}
+
+// nodeKey identifies an object in the graph
+func nodeKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// displayKey renders a node key as Kind/name
+func displayKey(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 {
+		return key
+	}
+	return parts[0] + "/" + parts[2]
+}
+
+// dig returns the value at the given path of nested maps, or nil
+func dig(v interface{}, fields ...string) interface{} {
+	for _, field := range fields {
+		switch m := v.(type) {
+		case manifest.Object:
+			v = m[field]
+		case map[string]interface{}:
+			v = m[field]
+		default:
+			return nil
+		}
+	}
+	return v
+}
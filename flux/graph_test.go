@@ -0,0 +1,19 @@
+package flux
+
+import (
+	"testing"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+func TestBuildGraphSkipsKustomizeConfig(t *testing.T) {
+	objects := []originObject{
+		{manifest.Object{"apiVersion": KustomizeAPIVersion, "kind": "Kustomization", "metadata": map[string]interface{}{"name": "demo-dev"}}, "flux.yaml"},
+		{manifest.Object{"apiVersion": "kustomize.config.k8s.io/v1beta1", "kind": "Kustomization", "metadata": map[string]interface{}{"name": "overlay"}}, "kustomization.yaml"},
+		{manifest.Object{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "settings"}}, "settings.yaml"},
+	}
+	graph := buildGraph(objects, "flux-system")
+	if len(graph.Nodes) != 1 || graph.Nodes[nodeKey("Kustomization", "flux-system", "demo-dev")] == nil {
+		t.Errorf("nodes = %v, want only Kustomization/demo-dev", graph.Nodes)
+	}
+}
@@ -0,0 +1,244 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// helmChartLayerMediaType is the OCI layer holding a packaged Helm chart
+const helmChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+// helmReleaseOptions holds the settings of the helmrelease command
+type helmReleaseOptions struct {
+	environment    string
+	chart          string
+	source         string
+	repoURL        string
+	version        string
+	valuesFiles    []string
+	release        string
+	retries        int
+	driftDetection string
+	outDir         string
+}
+
+// helmReleaseCmd creates a command to generate a Flux HelmRelease for a chart
+func helmReleaseCmd() *cobra.Command {
+	var opts helmReleaseOptions
+
+	cmd := &cobra.Command{
+		Use:   "helmrelease",
+		Short: "Generate a Flux HelmRelease for an environment",
+		Long: `Generate a HelmRelease deploying a chart to an environment, together with its source.
+
+Charts in this repository (--source git) are read from the project GitRepository, remote
+charts from a HelmRepository (--source helm) or an OCIRepository (--source oci) at
+--repo-url. The values files are merged in order into the release values; for charts in
+this repository <chart>/values-{environment}.yaml is used when no values files are given.
+
+Failed installs and upgrades are retried and rolled back, and drift detection corrects
+changes made to the release's objects in the cluster.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			generateHelmRelease(opts)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&opts.environment, "environment", "e", "dev", "Environment to deploy to")
+	cmd.Flags().StringVarP(&opts.chart, "chart", "c", "charts/troyops-helm-chart", "Chart path in this repository, or chart name for remote charts")
+	cmd.Flags().StringVar(&opts.source, "source", "git", "Chart source (git, helm, oci)")
+	cmd.Flags().StringVar(&opts.repoURL, "repo-url", "", "Helm repository URL, or OCI artifact URL for --source oci")
+	cmd.Flags().StringVar(&opts.version, "version", "", "Chart version or semver range for remote charts (defaults to the latest)")
+	cmd.Flags().StringSliceVarP(&opts.valuesFiles, "values", "f", nil, "Values files merged into the release values, later files take precedence")
+	cmd.Flags().StringVar(&opts.release, "release", "", "Helm release name (defaults to the app name)")
+	cmd.Flags().IntVar(&opts.retries, "retries", 3, "Install and upgrade retries before remediation gives up")
+	cmd.Flags().StringVar(&opts.driftDetection, "drift-detection", "enabled", "Drift detection mode (enabled, warn, disabled)")
+	cmd.Flags().StringVarP(&opts.outDir, "out", "o", "", "Directory to write the manifests to (defaults to flux.path from the config)")
+
+	return cmd
+}
+
+// generateHelmRelease writes the HelmRelease and its source for an environment
+func generateHelmRelease(opts helmReleaseOptions) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	env, err := cfg.Environment(opts.environment)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if opts.outDir == "" {
+		opts.outDir = cfg.Flux.Path
+	}
+	if opts.release == "" {
+		opts.release = cfg.App
+	}
+	switch opts.driftDetection {
+	case "enabled", "warn", "disabled":
+	default:
+		fmt.Printf("Unsupported drift detection mode: %s\n", opts.driftDetection)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s", opts.release, env.Name)
+	namespace := cfg.Flux.Namespace
+
+	release := HelmRelease{
+		APIVersion: HelmAPIVersion,
+		Kind:       "HelmRelease",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: HelmReleaseSpec{
+			Interval:        env.Interval,
+			Timeout:         env.Timeout,
+			ReleaseName:     opts.release,
+			TargetNamespace: env.Namespace,
+			Install: &HelmInstall{
+				CreateNamespace: true,
+				Remediation:     &HelmRemediation{Retries: opts.retries},
+			},
+			Upgrade: &HelmUpgrade{
+				CleanupOnFail: true,
+				Remediation:   &HelmRemediation{Retries: opts.retries, RemediateLastFailure: true, Strategy: "rollback"},
+			},
+			DriftDetection: &DriftDetection{Mode: opts.driftDetection},
+		},
+	}
+	for _, dep := range env.DependsOn {
+		release.Spec.DependsOn = append(release.Spec.DependsOn, DependencyReference{Name: fmt.Sprintf("%s-%s", opts.release, dep)})
+	}
+
+	// Build the chart source
+	var objects []interface{}
+	switch opts.source {
+	case "git":
+		if _, err := os.Stat(filepath.Join(opts.chart, "Chart.yaml")); err != nil {
+			fmt.Printf("Error: %s is not a chart in this repository\n", opts.chart)
+			return
+		}
+		if len(opts.valuesFiles) == 0 {
+			envValues := filepath.Join(opts.chart, fmt.Sprintf("values-%s.yaml", env.Name))
+			if _, err := os.Stat(envValues); err == nil {
+				opts.valuesFiles = []string{envValues}
+			}
+		}
+		// Revision makes Flux pick up chart changes committed without a version bump
+		release.Spec.Chart = &HelmChartTemplate{Spec: HelmChartTemplateSpec{
+			Chart:             "./" + filepath.ToSlash(filepath.Clean(opts.chart)),
+			SourceRef:         CrossNamespaceSourceReference{Kind: "GitRepository", Name: sourceName(cfg)},
+			ReconcileStrategy: "Revision",
+		}}
+	case "helm":
+		if opts.repoURL == "" {
+			fmt.Println("Error: --repo-url is required for --source helm")
+			return
+		}
+		sourceName := path.Base(opts.chart)
+		objects = append(objects, HelmRepository{
+			APIVersion: SourceAPIVersion,
+			Kind:       "HelmRepository",
+			Metadata:   ObjectMeta{Name: sourceName, Namespace: namespace, Labels: managedLabels("")},
+			Spec:       HelmRepositorySpec{Interval: "1h0m0s", URL: opts.repoURL},
+		})
+		version := opts.version
+		if version == "" {
+			version = "*"
+		}
+		release.Spec.Chart = &HelmChartTemplate{Spec: HelmChartTemplateSpec{
+			Chart:     opts.chart,
+			Version:   version,
+			SourceRef: CrossNamespaceSourceReference{Kind: "HelmRepository", Name: sourceName},
+			Interval:  env.Interval,
+		}}
+	case "oci":
+		if !strings.HasPrefix(opts.repoURL, "oci://") {
+			fmt.Println("Error: --repo-url must be an oci:// artifact URL for --source oci")
+			return
+		}
+		sourceName := path.Base(opts.repoURL)
+		ref := &OCIRepositoryRef{Tag: "latest"}
+		if opts.version != "" {
+			ref = &OCIRepositoryRef{SemVer: opts.version}
+		}
+		objects = append(objects, OCIRepository{
+			APIVersion: SourceAPIVersion,
+			Kind:       "OCIRepository",
+			Metadata:   ObjectMeta{Name: sourceName, Namespace: namespace, Labels: managedLabels("")},
+			Spec: OCIRepositorySpec{
+				Interval:      env.Interval,
+				URL:           opts.repoURL,
+				Ref:           ref,
+				LayerSelector: &OCILayerSelector{MediaType: helmChartLayerMediaType, Operation: "copy"},
+			},
+		})
+		release.Spec.ChartRef = &CrossNamespaceSourceReference{Kind: "OCIRepository", Name: sourceName}
+	default:
+		fmt.Printf("Unsupported chart source: %s\n", opts.source)
+		return
+	}
+
+	values, err := mergeValuesFiles(opts.valuesFiles)
+	if err != nil {
+		fmt.Println("Error reading values files:", err)
+		return
+	}
+	release.Spec.Values = values
+	objects = append(objects, release)
+
+	if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+	file := filepath.Join(opts.outDir, name+"-helmrelease.yaml")
+	if err := writeManifest(file, objects...); err != nil {
+		fmt.Println("Error writing HelmRelease:", err)
+		return
+	}
+	if len(opts.valuesFiles) > 0 {
+		fmt.Printf("Merged values from %s\n", strings.Join(opts.valuesFiles, ", "))
+	}
+
+	fmt.Println("HelmRelease generated successfully!")
+}
+
+// mergeValuesFiles reads Helm values files and merges them in order
+func mergeValuesFiles(files []string) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", file, err)
+		}
+		mergeValues(merged, values)
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// mergeValues deep merges src into dst the way Helm merges values files
+func mergeValues(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,268 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// imageAutomationOptions holds the settings of the image-automation command
+type imageAutomationOptions struct {
+	environment string
+	image       string
+	baseImage   string
+	policy      string
+	semverRange string
+	pattern     string
+	extract     string
+	order       string
+	interval    string
+	secretRef   string
+	pushBranch  string
+	outDir      string
+}
+
+// imageAutomationCmd creates a command to generate Flux image automation manifests
+func imageAutomationCmd() *cobra.Command {
+	var opts imageAutomationOptions
+
+	cmd := &cobra.Command{
+		Use:   "image-automation",
+		Short: "Generate Flux image automation for an environment",
+		Long: `Generate ImageRepository, ImagePolicy and ImageUpdateAutomation manifests for an
+environment and insert $imagepolicy setter markers into the images of its overlay's
+kustomization.yaml, so that Flux updates image tags in Git instead of the CI pipeline.
+
+Policies select tags either by semver range (--policy semver) or by a regular
+expression whose extracted value is ordered numerically (--policy regex).`,
+		Run: func(cmd *cobra.Command, args []string) {
+			generateImageAutomation(opts)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&opts.environment, "environment", "e", "dev", "Environment whose overlay is updated")
+	cmd.Flags().StringVarP(&opts.image, "image", "i", "", "Image repository to scan, e.g. ghcr.io/org/app (required)")
+	cmd.Flags().StringVar(&opts.baseImage, "base-image", "", "Image name used in the base manifests (defaults to --image)")
+	cmd.Flags().StringVar(&opts.policy, "policy", "semver", "Tag selection policy (semver, regex)")
+	cmd.Flags().StringVar(&opts.semverRange, "semver-range", ">=0.0.0", "Semver range for the semver policy")
+	cmd.Flags().StringVar(&opts.pattern, "pattern", `^main-[a-f0-9]+-(?P<ts>[0-9]+)$`, "Tag pattern for the regex policy")
+	cmd.Flags().StringVar(&opts.extract, "extract", "$ts", "Value extracted from the pattern and ordered for the regex policy")
+	cmd.Flags().StringVar(&opts.order, "order", "asc", "Order of extracted values for the regex policy (asc, desc)")
+	cmd.Flags().StringVar(&opts.interval, "interval", "5m0s", "How often the registry is scanned")
+	cmd.Flags().StringVar(&opts.secretRef, "secret-ref", "", "Secret with registry credentials")
+	cmd.Flags().StringVar(&opts.pushBranch, "push-branch", "", "Branch image updates are pushed to (defaults to repository.branch)")
+	cmd.Flags().StringVarP(&opts.outDir, "out", "o", "", "Directory to write the manifests to (defaults to flux.path from the config)")
+	cmd.MarkFlagRequired("image")
+
+	return cmd
+}
+
+// generateImageAutomation writes the image automation manifests and marks the overlay
+func generateImageAutomation(opts imageAutomationOptions) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	env, err := cfg.Environment(opts.environment)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if opts.outDir == "" {
+		opts.outDir = cfg.Flux.Path
+	}
+	if opts.baseImage == "" {
+		opts.baseImage = opts.image
+	}
+	if opts.pushBranch == "" {
+		opts.pushBranch = cfg.Repository.Branch
+	}
+
+	name := kustomizationName(cfg, env.Name)
+	namespace := cfg.Flux.Namespace
+
+	repository := ImageRepository{
+		APIVersion: ImageAPIVersion,
+		Kind:       "ImageRepository",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: ImageRepositorySpec{
+			Image:    opts.image,
+			Interval: opts.interval,
+		},
+	}
+	if opts.secretRef != "" {
+		repository.Spec.SecretRef = &LocalObjectReference{Name: opts.secretRef}
+	}
+
+	policy := ImagePolicy{
+		APIVersion: ImageAPIVersion,
+		Kind:       "ImagePolicy",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: ImagePolicySpec{
+			ImageRepositoryRef: LocalObjectReference{Name: name},
+		},
+	}
+	switch opts.policy {
+	case "semver":
+		policy.Spec.Policy.SemVer = &SemVerPolicy{Range: opts.semverRange}
+	case "regex":
+		if opts.order != "asc" && opts.order != "desc" {
+			fmt.Printf("Unsupported order: %s\n", opts.order)
+			return
+		}
+		policy.Spec.FilterTags = &TagFilter{Pattern: opts.pattern, Extract: opts.extract}
+		policy.Spec.Policy.Numerical = &OrderPolicy{Order: opts.order}
+	default:
+		fmt.Printf("Unsupported image policy: %s\n", opts.policy)
+		return
+	}
+
+	automation := ImageUpdateAutomation{
+		APIVersion: ImageAPIVersion,
+		Kind:       "ImageUpdateAutomation",
+		Metadata:   ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels(env.Name)},
+		Spec: ImageUpdateAutomationSpec{
+			Interval: opts.interval,
+			SourceRef: CrossNamespaceSourceReference{
+				Kind: "GitRepository",
+				Name: sourceName(cfg),
+			},
+			Git: GitCheckoutSpec{
+				Checkout: &GitCheckout{Ref: GitRepositoryRef{Branch: cfg.Repository.Branch}},
+				Commit: CommitSpec{
+					Author:          CommitUser{Name: "fluxcdbot", Email: "fluxcdbot@users.noreply.github.com"},
+					MessageTemplate: fmt.Sprintf("Update %s image in %s\n\n{{ range .Changed.Changes }}{{ .OldValue }} -> {{ .NewValue }}\n{{ end }}", cfg.App, env.Name),
+				},
+				Push: &PushSpec{Branch: opts.pushBranch},
+			},
+			Update: UpdateStrategy{
+				Path:     env.Overlay,
+				Strategy: "Setters",
+			},
+		},
+	}
+
+	if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+	file := filepath.Join(opts.outDir, name+"-image-automation.yaml")
+	if err := writeManifest(file, repository, policy, automation); err != nil {
+		fmt.Println("Error writing image automation manifests:", err)
+		return
+	}
+
+	kustomizationFile := filepath.Join(env.Overlay, "kustomization.yaml")
+	policyRef := namespace + ":" + name
+	if err := insertSetterMarkers(kustomizationFile, opts.baseImage, opts.image, policyRef); err != nil {
+		fmt.Println("Error inserting image policy markers:", err)
+		return
+	}
+	fmt.Printf("Inserted $imagepolicy markers for %s into %s\n", opts.baseImage, kustomizationFile)
+
+	fmt.Println("Image automation generated successfully!")
+	fmt.Printf("Note: The %s GitRepository needs credentials with write access for Flux to push updates.\n", sourceName(cfg))
+}
+
+// insertSetterMarkers points the overlay's images entry for baseImage at image and marks
+// newName and newTag with $imagepolicy setters so image automation can update them
+func insertSetterMarkers(kustomizationFile, baseImage, image, policyRef string) error {
+	data, err := os.ReadFile(kustomizationFile)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a YAML mapping", kustomizationFile)
+	}
+	root := doc.Content[0]
+
+	// Without an images field, a new block is appended to keep the existing layout intact
+	images := mappingValue(root, "images")
+	var appended *yaml.Node
+	if images == nil {
+		images = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		appended = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		appended.Content = append(appended.Content, scalarNode("images"), images)
+	}
+
+	var entry *yaml.Node
+	for _, item := range images.Content {
+		if n := mappingValue(item, "name"); n != nil && n.Value == baseImage {
+			entry = item
+			break
+		}
+	}
+	if entry == nil {
+		entry = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(entry, "name", baseImage)
+		images.Content = append(images.Content, entry)
+	}
+
+	tag := "latest"
+	if current := mappingValue(entry, "newTag"); current != nil && current.Value != "" {
+		tag = current.Value
+	}
+	setMappingValue(entry, "newName", image).LineComment = fmt.Sprintf(`# {"$imagepolicy": "%s:name"}`, policyRef)
+	setMappingValue(entry, "newTag", tag).LineComment = fmt.Sprintf(`# {"$imagepolicy": "%s:tag"}`, policyRef)
+
+	if appended != nil {
+		block, err := manifest.Encode(appended)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		data = append(append(data, '\n'), block...)
+		return os.WriteFile(kustomizationFile, data, 0644)
+	}
+
+	out, err := manifest.Encode(&doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(kustomizationFile, out, 0644)
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to a string value in a mapping node and returns the value node
+func setMappingValue(mapping *yaml.Node, key, value string) *yaml.Node {
+	if existing := mappingValue(mapping, key); existing != nil {
+		existing.Kind = yaml.ScalarNode
+		existing.Tag = "!!str"
+		existing.Value = value
+		return existing
+	}
+	node := scalarNode(value)
+	mapping.Content = append(mapping.Content, scalarNode(key), node)
+	return node
+}
+
+// scalarNode returns a string scalar node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,178 @@
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// fluxResource describes a Flux custom resource type
+type fluxResource struct {
+	Kind     string
+	Resource string
+}
+
+// matches reports whether obj is of the resource type, comparing the API group as well as the kind
+// so that kustomize.config.k8s.io Kustomizations are told apart from Flux ones
+func (r fluxResource) matches(obj manifest.Object) bool {
+	group := strings.SplitN(obj.APIVersion(), "/", 2)[0]
+	return obj.Kind() == r.Kind && group == r.Resource[strings.Index(r.Resource, ".")+1:]
+}
+
+// Flux custom resource types
+var (
+	gitRepositoryResource  = fluxResource{"GitRepository", "gitrepositories.source.toolkit.fluxcd.io"}
+	helmRepositoryResource = fluxResource{"HelmRepository", "helmrepositories.source.toolkit.fluxcd.io"}
+	ociRepositoryResource  = fluxResource{"OCIRepository", "ocirepositories.source.toolkit.fluxcd.io"}
+	kustomizationResource  = fluxResource{"Kustomization", "kustomizations.kustomize.toolkit.fluxcd.io"}
+	helmReleaseResource    = fluxResource{"HelmRelease", "helmreleases.helm.toolkit.fluxcd.io"}
+)
+
+// statusResources are the resource types reported by flux status, sources first
+var statusResources = []fluxResource{
+	gitRepositoryResource,
+	helmRepositoryResource,
+	ociRepositoryResource,
+	kustomizationResource,
+	helmReleaseResource,
+}
+
+// statusEntry is a row of the flux status report
+type statusEntry struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Ready      string `json:"ready"`
+	Suspended  bool   `json:"suspended"`
+	Revision   string `json:"revision,omitempty"`
+	ReadySince string `json:"readySince,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// statusCmd creates a command to report the readiness of Flux objects
+func statusCmd() *cobra.Command {
+	var namespace string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of Flux sources and reconcilers",
+		Long: `Read the GitRepository, HelmRepository, Kustomization and HelmRelease objects from the
+cluster and report their Ready condition and when it last changed, last applied revision
+and error messages. The command exits with a non-zero status when any object is not ready.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !reportStatus(namespace, output) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Only report objects in this namespace (defaults to all namespaces)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// reportStatus prints the status of all Flux objects and returns true when all are ready
+func reportStatus(namespace, output string) bool {
+	if output != "table" && output != "json" {
+		fmt.Printf("Unsupported output format: %s\n", output)
+		return false
+	}
+
+	entries := []statusEntry{}
+	for _, res := range statusResources {
+		var objects []LiveObject
+		if err := kube.List(res.Resource, namespace, "", &objects); err != nil {
+			// Controllers such as helm-controller are optional, so a missing type is not fatal
+			if strings.Contains(err.Error(), "the server doesn't have a resource type") {
+				continue
+			}
+			fmt.Printf("Error listing %s objects: %v\n", res.Kind, err)
+			return false
+		}
+		for _, obj := range objects {
+			entries = append(entries, newStatusEntry(res.Kind, obj))
+		}
+	}
+
+	allReady := true
+	for _, entry := range entries {
+		if entry.Ready != "True" {
+			allReady = false
+		}
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Println("Error encoding status:", err)
+			return false
+		}
+		fmt.Println(string(data))
+		return allReady
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No Flux objects found.")
+		return true
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tREADY\tSUSPENDED\tREVISION\tREADY SINCE\tMESSAGE")
+	for _, e := range entries {
+		message := ""
+		if e.Ready != "True" {
+			message = truncate(e.Message, 80)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\n",
+			e.Kind, e.Namespace, e.Name, e.Ready, e.Suspended, e.Revision, since(e.ReadySince), message)
+	}
+	w.Flush()
+
+	return allReady
+}
+
+// newStatusEntry summarises a live object's status
+func newStatusEntry(kind string, obj LiveObject) statusEntry {
+	entry := statusEntry{
+		Kind:      kind,
+		Namespace: obj.Metadata.Namespace,
+		Name:      obj.Metadata.Name,
+		Ready:     "Unknown",
+		Suspended: obj.Spec.Suspend,
+		Revision:  obj.Status.Revision(),
+	}
+	if ready := obj.Status.Condition("Ready"); ready != nil {
+		entry.Ready = ready.Status
+		entry.ReadySince = ready.LastTransitionTime
+		entry.Message = ready.Message
+	}
+	return entry
+}
+
+// since formats an RFC 3339 timestamp as the time elapsed since then
+func since(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,21 @@
+package flux
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"line one\nline two", 20, "line one line two"},
+		{"reconciliation failed", 10, "reconci..."},
+		{"échec de réconciliation", 10, "échec d..."},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
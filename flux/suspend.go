@@ -0,0 +1,296 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/spf13/cobra"
+)
+
+// Annotations recording who suspended an object, why and when
+const (
+	suspendedByAnnotation     = "troyops.io/suspended-by"
+	suspendedReasonAnnotation = "troyops.io/suspended-reason"
+	suspendedAtAnnotation     = "troyops.io/suspended-at"
+)
+
+// suspendableResources are the Flux reconcilers that can be suspended
+var suspendableResources = []fluxResource{kustomizationResource, helmReleaseResource}
+
+// target is a live Flux object together with its resource type
+type target struct {
+	res fluxResource
+	obj LiveObject
+}
+
+// selection identifies the Flux objects a command operates on
+type selection struct {
+	environment string
+	kind        string
+	namespace   string
+}
+
+// addFlags registers the selection flags on a command
+func (s *selection) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&s.environment, "environment", "e", "", "Select the objects of this environment")
+	cmd.Flags().StringVar(&s.kind, "kind", "", "Only select this kind (kustomization, helmrelease)")
+	cmd.Flags().StringVarP(&s.namespace, "namespace", "n", "", "Namespace of the objects (defaults to flux.namespace from the config)")
+}
+
+// resolve returns the objects matching the selection and the optional object name
+func (s *selection) resolve(name string) ([]target, error) {
+	if name == "" && s.environment == "" {
+		return nil, fmt.Errorf("specify an object name or --environment")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	namespace := s.namespace
+	if namespace == "" {
+		namespace = cfg.Flux.Namespace
+	}
+	selector := ""
+	if s.environment != "" {
+		selector = EnvironmentLabel + "=" + s.environment
+	}
+
+	var selected []target
+	for _, res := range suspendableResources {
+		if s.kind != "" && !strings.EqualFold(s.kind, res.Kind) {
+			continue
+		}
+
+		var objects []LiveObject
+		if err := kube.List(res.Resource, namespace, selector, &objects); err != nil {
+			if strings.Contains(err.Error(), "the server doesn't have a resource type") {
+				continue
+			}
+			return nil, err
+		}
+		for _, obj := range objects {
+			if name == "" || obj.Metadata.Name == name {
+				selected = append(selected, target{res, obj})
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no matching Kustomizations or HelmReleases found in namespace %s", namespace)
+	}
+	return selected, nil
+}
+
+// suspendCmd creates a command to suspend Flux reconciliation
+func suspendCmd() *cobra.Command {
+	var sel selection
+	var reason string
+	var by string
+
+	cmd := &cobra.Command{
+		Use:   "suspend [name]",
+		Short: "Suspend reconciliation of Kustomizations and HelmReleases",
+		Long: `Suspend Flux reconciliation so that manual changes, such as hotfixes during an
+incident, are not reverted. Who suspended the object and why is recorded in annotations.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			setSuspended(sel, name, true, reason, by)
+		},
+	}
+
+	// Add flags
+	sel.addFlags(cmd)
+	cmd.Flags().StringVar(&reason, "reason", "", "Why reconciliation is suspended (required)")
+	cmd.Flags().StringVar(&by, "by", "", "Who is suspending (defaults to git user.email or $USER)")
+	cmd.MarkFlagRequired("reason")
+
+	return cmd
+}
+
+// resumeCmd creates a command to resume Flux reconciliation
+func resumeCmd() *cobra.Command {
+	var sel selection
+
+	cmd := &cobra.Command{
+		Use:   "resume [name]",
+		Short: "Resume reconciliation of Kustomizations and HelmReleases",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			setSuspended(sel, name, false, "", "")
+		},
+	}
+
+	// Add flags
+	sel.addFlags(cmd)
+
+	return cmd
+}
+
+// setSuspended suspends or resumes the selected objects
+func setSuspended(sel selection, name string, suspend bool, reason, by string) {
+	selected, err := sel.resolve(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if suspend && by == "" {
+		by = currentUser()
+	}
+
+	for _, t := range selected {
+		res, obj := t.res, t.obj
+		patch := map[string]interface{}{
+			"spec": map[string]interface{}{"suspend": suspend},
+		}
+		if suspend {
+			patch["metadata"] = map[string]interface{}{
+				"annotations": map[string]interface{}{
+					suspendedByAnnotation:     by,
+					suspendedReasonAnnotation: reason,
+					suspendedAtAnnotation:     time.Now().UTC().Format(time.RFC3339),
+				},
+			}
+		} else {
+			// Clear the suspension record and reconcile straight away
+			patch["metadata"] = map[string]interface{}{
+				"annotations": map[string]interface{}{
+					suspendedByAnnotation:      nil,
+					suspendedReasonAnnotation:  nil,
+					suspendedAtAnnotation:      nil,
+					reconcileRequestAnnotation: time.Now().UTC().Format(time.RFC3339Nano),
+				},
+			}
+		}
+
+		if err := kube.Patch(res.Resource, obj.Metadata.Namespace, obj.Metadata.Name, patch); err != nil {
+			fmt.Printf("Error updating %s/%s: %v\n", res.Kind, obj.Metadata.Name, err)
+			continue
+		}
+		if suspend {
+			fmt.Printf("Suspended %s/%s (by %s: %s)\n", res.Kind, obj.Metadata.Name, by, reason)
+		} else {
+			fmt.Printf("Resumed %s/%s\n", res.Kind, obj.Metadata.Name)
+		}
+	}
+}
+
+// suspendedCmd creates a command to list suspended Flux objects
+func suspendedCmd() *cobra.Command {
+	var namespace string
+	var warnAfter time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "suspended",
+		Short: "List suspended Kustomizations and HelmReleases",
+		Long: `List the suspended Kustomizations and HelmReleases with who suspended them and why,
+and warn about objects that have been suspended for longer than --warn-after.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			listSuspended(namespace, warnAfter)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Only list objects in this namespace (defaults to all namespaces)")
+	cmd.Flags().DurationVar(&warnAfter, "warn-after", 24*time.Hour, "Warn about objects suspended for longer than this")
+
+	return cmd
+}
+
+// listSuspended prints the suspended objects and warns about long suspensions
+func listSuspended(namespace string, warnAfter time.Duration) {
+	type suspendedObject struct {
+		kind string
+		obj  LiveObject
+		age  time.Duration
+	}
+
+	var suspended []suspendedObject
+	for _, res := range suspendableResources {
+		var objects []LiveObject
+		if err := kube.List(res.Resource, namespace, "", &objects); err != nil {
+			if strings.Contains(err.Error(), "the server doesn't have a resource type") {
+				continue
+			}
+			fmt.Printf("Error listing %s objects: %v\n", res.Kind, err)
+			return
+		}
+		for _, obj := range objects {
+			if !obj.Spec.Suspend {
+				continue
+			}
+			age := time.Duration(-1)
+			if at, err := time.Parse(time.RFC3339, obj.Metadata.Annotations[suspendedAtAnnotation]); err == nil {
+				age = time.Since(at)
+			}
+			suspended = append(suspended, suspendedObject{res.Kind, obj, age})
+		}
+	}
+
+	if len(suspended) == 0 {
+		fmt.Println("No suspended objects found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tSUSPENDED FOR\tBY\tREASON")
+	for _, s := range suspended {
+		duration := "unknown"
+		if s.age >= 0 {
+			duration = formatDuration(s.age)
+		}
+		annotations := s.obj.Metadata.Annotations
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.kind, s.obj.Metadata.Namespace, s.obj.Metadata.Name,
+			duration, annotations[suspendedByAnnotation], annotations[suspendedReasonAnnotation])
+	}
+	w.Flush()
+
+	for _, s := range suspended {
+		if s.age > warnAfter {
+			fmt.Printf("Warning: %s/%s has been suspended for %s, longer than %s\n",
+				s.kind, s.obj.Metadata.Name, formatDuration(s.age), formatDuration(warnAfter))
+		}
+	}
+}
+
+// currentUser identifies the person running the command
+func currentUser() string {
+	if out, err := exec.Command("git", "config", "user.email").Output(); err == nil {
+		if email := strings.TrimSpace(string(out)); email != "" {
+			return email
+		}
+	}
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "unknown"
+}
+
+// formatDuration renders a duration in days, hours and minutes
+func formatDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh", days, hours)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
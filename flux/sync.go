@@ -0,0 +1,274 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/spf13/cobra"
+)
+
+// reconcileRequestAnnotation asks a Flux controller to reconcile an object out of schedule
+const reconcileRequestAnnotation = "reconcile.fluxcd.io/requestedAt"
+
+// pollInterval is how often object status is checked while waiting
+const pollInterval = 2 * time.Second
+
+// sourceResources maps Flux source kinds to their resource types
+var sourceResources = map[string]fluxResource{
+	gitRepositoryResource.Kind:  gitRepositoryResource,
+	helmRepositoryResource.Kind: helmRepositoryResource,
+	ociRepositoryResource.Kind:  ociRepositoryResource,
+}
+
+// syncCmd creates a command to reconcile Flux sources and Kustomizations and wait for them
+func syncCmd() *cobra.Command {
+	var environment string
+	var kustomization string
+	var source string
+	var revision string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Trigger Flux synchronization",
+		Long: `Reconcile a Flux source and the Kustomizations depending on it, in dependsOn order,
+and wait until each one has applied the expected Git revision.
+
+Select what to sync with --environment, --kustomization or --source; without any of
+them every Kustomization in the Flux namespace is synced. The expected revision
+defaults to the commit checked out in the current repository (git rev-parse HEAD).`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !syncFlux(environment, kustomization, source, revision, timeout) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&environment, "environment", "e", "", "Sync the Kustomization of this environment")
+	cmd.Flags().StringVarP(&kustomization, "kustomization", "k", "", "Sync the named Kustomization")
+	cmd.Flags().StringVarP(&source, "source", "s", "", "Sync the named GitRepository and the Kustomizations using it")
+	cmd.Flags().StringVar(&revision, "revision", "", "Git SHA the objects must reach (defaults to the local HEAD)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "How long to wait for each object")
+	cmd.MarkFlagsMutuallyExclusive("environment", "kustomization", "source")
+
+	return cmd
+}
+
+// syncFlux reconciles the selected sources and Kustomizations and returns true when all reached the revision
+func syncFlux(environment, kustomization, source, revision string, timeout time.Duration) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return false
+	}
+	namespace := cfg.Flux.Namespace
+
+	var kustomizations []LiveObject
+	if err := kube.List(kustomizationResource.Resource, namespace, "", &kustomizations); err != nil {
+		fmt.Println("Error listing Kustomizations:", err)
+		return false
+	}
+
+	// Select the Kustomizations to sync
+	if environment != "" {
+		kustomization = kustomizationName(cfg, environment)
+	}
+	var targets []LiveObject
+	for _, k := range kustomizations {
+		switch {
+		case kustomization != "":
+			if k.Metadata.Name == kustomization {
+				targets = append(targets, k)
+			}
+		case source != "":
+			if k.Spec.SourceRef.Name == source {
+				targets = append(targets, k)
+			}
+		default:
+			targets = append(targets, k)
+		}
+	}
+	if kustomization != "" && len(targets) == 0 {
+		fmt.Printf("Error: Kustomization %s not found in namespace %s\n", kustomization, namespace)
+		return false
+	}
+
+	ordered, err := orderByDependencies(targets)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	// Collect the sources of the selected Kustomizations
+	type sourceKey struct{ kind, namespace, name string }
+	var sources []sourceKey
+	seen := map[sourceKey]bool{}
+	if source != "" {
+		key := sourceKey{gitRepositoryResource.Kind, namespace, source}
+		sources = append(sources, key)
+		seen[key] = true
+	}
+	for _, k := range ordered {
+		ref := k.Spec.SourceRef
+		key := sourceKey{ref.Kind, ref.Namespace, ref.Name}
+		if key.namespace == "" {
+			key.namespace = k.Metadata.Namespace
+		}
+		if !seen[key] {
+			sources = append(sources, key)
+			seen[key] = true
+		}
+	}
+
+	if revision == "" {
+		revision, err = localRevision()
+		if err != nil {
+			fmt.Println("Warning: Could not determine the local Git revision, skipping revision verification:", err)
+		}
+	}
+	if revision != "" {
+		fmt.Printf("Waiting for revision %s...\n", revision)
+	}
+
+	start := time.Now()
+	for _, src := range sources {
+		res, ok := sourceResources[src.kind]
+		if !ok {
+			fmt.Printf("Warning: Skipping unsupported source kind %s/%s\n", src.kind, src.name)
+			continue
+		}
+		if err := reconcileAndWait(res, src.namespace, src.name, gitRevision(src.kind, revision), timeout); err != nil {
+			fmt.Printf("Error syncing %s/%s: %v\n", res.Kind, src.name, err)
+			return false
+		}
+	}
+	for _, k := range ordered {
+		if err := reconcileAndWait(kustomizationResource, k.Metadata.Namespace, k.Metadata.Name, gitRevision(k.Spec.SourceRef.Kind, revision), timeout); err != nil {
+			fmt.Printf("Error syncing Kustomization/%s: %v\n", k.Metadata.Name, err)
+			return false
+		}
+	}
+
+	fmt.Printf("Flux synchronization completed successfully in %s!\n", time.Since(start).Round(100*time.Millisecond))
+	return true
+}
+
+// reconcileAndWait requests a reconciliation of an object and waits until it is ready at the revision
+func reconcileAndWait(res fluxResource, namespace, name, revision string, timeout time.Duration) error {
+	start := time.Now()
+	token := start.UTC().Format(time.RFC3339Nano)
+
+	fmt.Printf("Reconciling %s/%s...\n", res.Kind, name)
+	if err := kube.Annotate(res.Resource, namespace, name, map[string]string{reconcileRequestAnnotation: token}); err != nil {
+		return err
+	}
+
+	for {
+		var obj LiveObject
+		if err := kube.Get(res.Resource, namespace, name, &obj); err != nil {
+			return err
+		}
+		if obj.Spec.Suspend {
+			return fmt.Errorf("object is suspended")
+		}
+
+		if obj.Status.LastHandledReconcileAt == token {
+			ready := obj.Status.Condition("Ready")
+			switch {
+			case ready == nil:
+			case ready.Status == "True":
+				current := obj.Status.Revision()
+				if revision != "" && !strings.Contains(current, revision) {
+					return fmt.Errorf("reconciled revision %s, expected %s", current, revision)
+				}
+				fmt.Printf("  %s/%s ready at %s (%s)\n", res.Kind, name, current, time.Since(start).Round(100*time.Millisecond))
+				return nil
+			case ready.Status == "False" && ready.Reason != "DependencyNotReady" && ready.Reason != "Progressing":
+				return fmt.Errorf("%s: %s", ready.Reason, ready.Message)
+			}
+		}
+
+		if time.Since(start) > timeout {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// orderByDependencies sorts Kustomizations so that every object comes after the ones it depends on.
+// Dependencies outside the given set are ignored.
+func orderByDependencies(kustomizations []LiveObject) ([]LiveObject, error) {
+	byName := map[string]LiveObject{}
+	for _, k := range kustomizations {
+		byName[k.Metadata.Namespace+"/"+k.Metadata.Name] = k
+	}
+
+	// Count the in-set dependencies of every object
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for key, k := range byName {
+		pending[key] = 0
+		for _, dep := range k.Spec.DependsOn {
+			depNamespace := dep.Namespace
+			if depNamespace == "" {
+				depNamespace = k.Metadata.Namespace
+			}
+			depKey := depNamespace + "/" + dep.Name
+			if _, ok := byName[depKey]; ok {
+				pending[key]++
+				dependents[depKey] = append(dependents[depKey], key)
+			}
+		}
+	}
+
+	var ready []string
+	for key, count := range pending {
+		if count == 0 {
+			ready = append(ready, key)
+		}
+	}
+
+	var ordered []LiveObject
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		key := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byName[key])
+		for _, dependent := range dependents[key] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(ordered) != len(byName) {
+		return nil, fmt.Errorf("dependsOn cycle detected between Kustomizations")
+	}
+	return ordered, nil
+}
+
+// gitRevision returns the revision to verify for objects from a source kind.
+// Only GitRepository artifacts carry a Git SHA; OCI, Bucket and Helm sources are revisioned otherwise.
+func gitRevision(sourceKind, revision string) string {
+	if sourceKind != gitRepositoryResource.Kind {
+		return ""
+	}
+	return revision
+}
+
+// localRevision returns the commit SHA checked out in the current repository
+func localRevision() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,88 @@
+package flux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderByDependencies(t *testing.T) {
+	kustomization := func(namespace, name string, deps ...DependencyReference) LiveObject {
+		k := LiveObject{Kind: "Kustomization", Metadata: ObjectMeta{Name: name, Namespace: namespace}}
+		k.Spec.DependsOn = deps
+		return k
+	}
+	dep := func(name string) DependencyReference { return DependencyReference{Name: name} }
+
+	tests := []struct {
+		name    string
+		input   []LiveObject
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "chain",
+			input: []LiveObject{kustomization("flux-system", "prod", dep("staging")), kustomization("flux-system", "staging", dep("dev")), kustomization("flux-system", "dev")},
+			want:  []string{"dev", "staging", "prod"},
+		},
+		{
+			name:  "independent objects sorted by name",
+			input: []LiveObject{kustomization("flux-system", "b"), kustomization("flux-system", "a")},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "dependency outside the set",
+			input: []LiveObject{kustomization("flux-system", "apps", dep("infrastructure"))},
+			want:  []string{"apps"},
+		},
+		{
+			name: "dependency in another namespace",
+			input: []LiveObject{
+				kustomization("team", "apps", DependencyReference{Name: "infra", Namespace: "flux-system"}),
+				kustomization("flux-system", "infra"),
+			},
+			want: []string{"infra", "apps"},
+		},
+		{
+			name:    "cycle",
+			input:   []LiveObject{kustomization("flux-system", "a", dep("b")), kustomization("flux-system", "b", dep("a"))},
+			wantErr: true,
+		},
+		{
+			name:    "self dependency",
+			input:   []LiveObject{kustomization("flux-system", "a", dep("a"))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := orderByDependencies(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("orderByDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, k := range ordered {
+				got = append(got, k.Metadata.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderByDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitRevision(t *testing.T) {
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{"GitRepository", "abc123"},
+		{"OCIRepository", ""},
+		{"Bucket", ""},
+		{"HelmRepository", ""},
+	}
+	for _, tt := range tests {
+		if got := gitRevision(tt.kind, "abc123"); got != tt.want {
+			t.Errorf("gitRevision(%s) = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
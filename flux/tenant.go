@@ -0,0 +1,236 @@
+package flux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/spf13/cobra"
+)
+
+// tenantClusterRole is the role tenants get within their own namespace
+const tenantClusterRole = "admin"
+
+// tenantOptions holds the settings of the tenant add command
+type tenantOptions struct {
+	repo           string
+	branch         string
+	path           string
+	interval       string
+	sourceInterval string
+	secretRef      string
+	outDir         string
+}
+
+// tenantCmd creates a command to manage teams sharing the cluster
+func tenantCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tenant",
+		Short: "Manage isolated Flux tenants",
+	}
+
+	cmd.AddCommand(tenantAddCmd())
+	cmd.AddCommand(tenantLintCmd())
+
+	return cmd
+}
+
+// tenantAddCmd creates a command to generate the layout of a tenant
+func tenantAddCmd() *cobra.Command {
+	var opts tenantOptions
+
+	cmd := &cobra.Command{
+		Use:   "add <team>",
+		Short: "Generate the namespace, RBAC and sync objects of a tenant",
+		Long: `Generate a namespace for a team with a service account bound to the admin role in that
+namespace only, and a GitRepository and Kustomization syncing the team's repository.
+
+The Kustomization impersonates the tenant service account and is locked to the tenant
+namespace with targetNamespace, so a team cannot change objects outside its namespace.
+The manifests are written to <out>/<team> for the cluster Flux configuration to apply.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			addTenant(args[0], opts)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&opts.repo, "repo", "r", "", "Git repository of the tenant (required)")
+	cmd.Flags().StringVarP(&opts.branch, "branch", "b", "main", "Branch of the tenant repository")
+	cmd.Flags().StringVarP(&opts.path, "path", "p", "./", "Path to the tenant manifests in its repository")
+	cmd.Flags().StringVar(&opts.interval, "interval", "5m0s", "How often the tenant repository is reconciled")
+	cmd.Flags().StringVar(&opts.sourceInterval, "source-interval", defaultSourceInterval, "How often the tenant repository is fetched for new commits")
+	cmd.Flags().StringVar(&opts.secretRef, "secret-ref", "", "Secret with credentials for the tenant repository")
+	cmd.Flags().StringVarP(&opts.outDir, "out", "o", "", "Directory for tenant manifests (defaults to tenants next to flux.path)")
+	cmd.MarkFlagRequired("repo")
+
+	return cmd
+}
+
+// addTenant writes the RBAC and sync manifests of a tenant
+func addTenant(team string, opts tenantOptions) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return
+	}
+	if opts.outDir == "" {
+		opts.outDir = filepath.Join(filepath.Dir(cfg.Flux.Path), "tenants")
+	}
+
+	labels := map[string]string{ManagedByLabel: ManagedByValue, TenantLabel: team}
+	meta := func(kind string) ObjectMeta {
+		m := ObjectMeta{Name: team, Namespace: team, Labels: labels}
+		if kind == "Namespace" {
+			m.Namespace = ""
+		}
+		return m
+	}
+
+	namespace := TypedObject{APIVersion: "v1", Kind: "Namespace", Metadata: meta("Namespace")}
+	serviceAccount := TypedObject{APIVersion: "v1", Kind: "ServiceAccount", Metadata: meta("ServiceAccount")}
+	roleBinding := RoleBinding{
+		APIVersion: "rbac.authorization.k8s.io/v1",
+		Kind:       "RoleBinding",
+		Metadata:   ObjectMeta{Name: team + "-reconciler", Namespace: team, Labels: labels},
+		RoleRef:    RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: tenantClusterRole},
+		Subjects: []Subject{
+			{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: fmt.Sprintf("gotk:%s:reconciler", team)},
+			{Kind: "ServiceAccount", Name: team, Namespace: team},
+		},
+	}
+
+	repository := GitRepository{
+		APIVersion: SourceAPIVersion,
+		Kind:       "GitRepository",
+		Metadata:   meta("GitRepository"),
+		Spec: GitRepositorySpec{
+			Interval: opts.sourceInterval,
+			URL:      opts.repo,
+			Ref:      &GitRepositoryRef{Branch: opts.branch},
+		},
+	}
+	if opts.secretRef != "" {
+		repository.Spec.SecretRef = &LocalObjectReference{Name: opts.secretRef}
+	}
+	kustomization := Kustomization{
+		APIVersion: KustomizeAPIVersion,
+		Kind:       "Kustomization",
+		Metadata:   meta("Kustomization"),
+		Spec: KustomizationSpec{
+			Interval:           opts.interval,
+			Path:               opts.path,
+			Prune:              true,
+			SourceRef:          CrossNamespaceSourceReference{Kind: "GitRepository", Name: team},
+			TargetNamespace:    team,
+			ServiceAccountName: team,
+		},
+	}
+
+	dir := filepath.Join(opts.outDir, team)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("Error creating tenant directory:", err)
+		return
+	}
+	if err := writeManifest(filepath.Join(dir, "rbac.yaml"), namespace, serviceAccount, roleBinding); err != nil {
+		fmt.Println("Error writing tenant RBAC:", err)
+		return
+	}
+	if err := writeManifest(filepath.Join(dir, "sync.yaml"), repository, kustomization); err != nil {
+		fmt.Println("Error writing tenant sync:", err)
+		return
+	}
+
+	fmt.Printf("Tenant %s generated successfully!\n", team)
+}
+
+// tenantLintCmd creates a command to check that tenant reconcilers are isolated
+func tenantLintCmd() *cobra.Command {
+	var dir string
+	var live bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Reject tenant Kustomizations and HelmReleases without impersonation",
+		Long: `Check that every Kustomization and HelmRelease of a tenant, that is outside the Flux
+namespace or labelled with troyops.io/tenant, impersonates a service account and does
+not target another namespace. Without impersonation a tenant object is applied with
+the cluster-wide permissions of the Flux controllers.
+
+The manifests under the Flux directory are checked, and with --live also the objects
+in the cluster. The command exits with status 1 when a violation is found.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !lintTenants(dir, live) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Directory with Flux manifests (defaults to the parent of flux.path)")
+	cmd.Flags().BoolVar(&live, "live", false, "Also check the Flux objects in all namespaces of the cluster")
+
+	return cmd
+}
+
+// lintTenants prints the isolation violations and returns true when there are none
+func lintTenants(dir string, live bool) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return false
+	}
+	if dir == "" {
+		dir = filepath.Dir(cfg.Flux.Path)
+	}
+
+	objects, err := readManifests(dir)
+	if err != nil {
+		fmt.Println("Error reading Flux manifests:", err)
+		return false
+	}
+	if live {
+		liveObjects, err := listLiveObjects("")
+		if err != nil {
+			fmt.Println("Error listing Flux objects:", err)
+			return false
+		}
+		objects = append(objects, liveObjects...)
+	}
+
+	var violations []string
+	checked := 0
+	for _, o := range objects {
+		obj := o.obj
+		if !kustomizationResource.matches(obj) && !helmReleaseResource.matches(obj) {
+			continue
+		}
+		namespace := obj.Namespace()
+		labels, _ := dig(obj, "metadata", "labels").(map[string]interface{})
+		if _, tenant := labels[TenantLabel]; !tenant && (namespace == "" || namespace == cfg.Flux.Namespace) {
+			continue
+		}
+		checked++
+
+		id := fmt.Sprintf("%s/%s in %s (%s)", obj.Kind(), obj.Name(), namespace, o.origin)
+		if sa, _ := dig(obj, "spec", "serviceAccountName").(string); sa == "" {
+			violations = append(violations, id+": no serviceAccountName, it is applied without impersonation")
+		}
+		if target, _ := dig(obj, "spec", "targetNamespace").(string); target != "" && namespace != "" && target != namespace {
+			violations = append(violations, fmt.Sprintf("%s: targetNamespace %s is outside the tenant namespace", id, target))
+		}
+	}
+
+	sort.Strings(violations)
+	for _, v := range violations {
+		fmt.Println("Error:", v)
+	}
+	if len(violations) > 0 {
+		fmt.Printf("%d tenant isolation violation(s) found\n", len(violations))
+		return false
+	}
+	fmt.Printf("Checked %d tenant object(s), no violations found.\n", checked)
+	return true
+}
@@ -0,0 +1,472 @@
+package flux
+
+// API versions of the Flux custom resources generated by TroyOps
+const (
+	SourceAPIVersion    = "source.toolkit.fluxcd.io/v1"
+	KustomizeAPIVersion = "kustomize.toolkit.fluxcd.io/v1"
+	ImageAPIVersion     = "image.toolkit.fluxcd.io/v1beta2"
+	NotifyAPIVersion    = "notification.toolkit.fluxcd.io/v1beta3"
+	HelmAPIVersion      = "helm.toolkit.fluxcd.io/v2"
+)
+
+// Labels set on every object generated by TroyOps
+const (
+	ManagedByLabel   = "app.kubernetes.io/managed-by"
+	ManagedByValue   = "troyops"
+	EnvironmentLabel = "troyops.io/environment"
+	TenantLabel      = "troyops.io/tenant"
+)
+
+// Labels kustomize-controller sets on the objects a Kustomization applies
+const (
+	AppliedByNameLabel      = "kustomize.toolkit.fluxcd.io/name"
+	AppliedByNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+)
+
+// ObjectMeta is the subset of Kubernetes object metadata used by TroyOps
+type ObjectMeta struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// LocalObjectReference references an object in the same namespace
+type LocalObjectReference struct {
+	Name string `yaml:"name"`
+}
+
+// CrossNamespaceSourceReference references a Flux source
+type CrossNamespaceSourceReference struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// DependencyReference references a Flux object that must be ready first
+type DependencyReference struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// NamespacedObjectKindReference references an object used for health checking
+type NamespacedObjectKindReference struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// GitRepository is a Flux source pointing at a Git repository
+type GitRepository struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Spec       GitRepositorySpec `yaml:"spec"`
+}
+
+// GitRepositorySpec is the desired state of a GitRepository
+type GitRepositorySpec struct {
+	Interval  string                `yaml:"interval"`
+	URL       string                `yaml:"url"`
+	Ref       *GitRepositoryRef     `yaml:"ref,omitempty"`
+	SecretRef *LocalObjectReference `yaml:"secretRef,omitempty"`
+	Timeout   string                `yaml:"timeout,omitempty"`
+}
+
+// GitRepositoryRef selects the Git reference to check out
+type GitRepositoryRef struct {
+	Branch string `yaml:"branch,omitempty"`
+	Tag    string `yaml:"tag,omitempty"`
+	SemVer string `yaml:"semver,omitempty"`
+	Commit string `yaml:"commit,omitempty"`
+}
+
+// Kustomization is a Flux object applying a path from a source to the cluster
+type Kustomization struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Spec       KustomizationSpec `yaml:"spec"`
+}
+
+// KustomizationSpec is the desired state of a Kustomization
+type KustomizationSpec struct {
+	Interval           string                          `yaml:"interval"`
+	Timeout            string                          `yaml:"timeout,omitempty"`
+	Path               string                          `yaml:"path"`
+	Prune              bool                            `yaml:"prune"`
+	SourceRef          CrossNamespaceSourceReference   `yaml:"sourceRef"`
+	DependsOn          []DependencyReference           `yaml:"dependsOn,omitempty"`
+	HealthChecks       []NamespacedObjectKindReference `yaml:"healthChecks,omitempty"`
+	TargetNamespace    string                          `yaml:"targetNamespace,omitempty"`
+	ServiceAccountName string                          `yaml:"serviceAccountName,omitempty"`
+	Decryption         *Decryption                     `yaml:"decryption,omitempty"`
+	Suspend            bool                            `yaml:"suspend,omitempty"`
+}
+
+// Decryption configures how a Kustomization decrypts secrets before applying them
+type Decryption struct {
+	Provider  string                `yaml:"provider"`
+	SecretRef *LocalObjectReference `yaml:"secretRef,omitempty"`
+}
+
+// ImageRepository scans a container registry for image tags
+type ImageRepository struct {
+	APIVersion string              `yaml:"apiVersion"`
+	Kind       string              `yaml:"kind"`
+	Metadata   ObjectMeta          `yaml:"metadata"`
+	Spec       ImageRepositorySpec `yaml:"spec"`
+}
+
+// ImageRepositorySpec is the desired state of an ImageRepository
+type ImageRepositorySpec struct {
+	Image     string                `yaml:"image"`
+	Interval  string                `yaml:"interval"`
+	SecretRef *LocalObjectReference `yaml:"secretRef,omitempty"`
+}
+
+// ImagePolicy selects the latest image tag from an ImageRepository
+type ImagePolicy struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   ObjectMeta      `yaml:"metadata"`
+	Spec       ImagePolicySpec `yaml:"spec"`
+}
+
+// ImagePolicySpec is the desired state of an ImagePolicy
+type ImagePolicySpec struct {
+	ImageRepositoryRef LocalObjectReference `yaml:"imageRepositoryRef"`
+	FilterTags         *TagFilter           `yaml:"filterTags,omitempty"`
+	Policy             ImagePolicyChoice    `yaml:"policy"`
+}
+
+// TagFilter restricts the tags considered by an ImagePolicy
+type TagFilter struct {
+	Pattern string `yaml:"pattern"`
+	Extract string `yaml:"extract,omitempty"`
+}
+
+// ImagePolicyChoice holds exactly one tag ordering policy
+type ImagePolicyChoice struct {
+	SemVer       *SemVerPolicy `yaml:"semver,omitempty"`
+	Numerical    *OrderPolicy  `yaml:"numerical,omitempty"`
+	Alphabetical *OrderPolicy  `yaml:"alphabetical,omitempty"`
+}
+
+// SemVerPolicy selects the highest tag within a semver range
+type SemVerPolicy struct {
+	Range string `yaml:"range"`
+}
+
+// OrderPolicy selects the first tag in the given order
+type OrderPolicy struct {
+	Order string `yaml:"order"`
+}
+
+// ImageUpdateAutomation commits image policy updates back to Git
+type ImageUpdateAutomation struct {
+	APIVersion string                    `yaml:"apiVersion"`
+	Kind       string                    `yaml:"kind"`
+	Metadata   ObjectMeta                `yaml:"metadata"`
+	Spec       ImageUpdateAutomationSpec `yaml:"spec"`
+}
+
+// ImageUpdateAutomationSpec is the desired state of an ImageUpdateAutomation
+type ImageUpdateAutomationSpec struct {
+	Interval  string                        `yaml:"interval"`
+	SourceRef CrossNamespaceSourceReference `yaml:"sourceRef"`
+	Git       GitCheckoutSpec               `yaml:"git"`
+	Update    UpdateStrategy                `yaml:"update"`
+}
+
+// GitCheckoutSpec describes how image updates are committed and pushed
+type GitCheckoutSpec struct {
+	Checkout *GitCheckout `yaml:"checkout,omitempty"`
+	Commit   CommitSpec   `yaml:"commit"`
+	Push     *PushSpec    `yaml:"push,omitempty"`
+}
+
+// GitCheckout selects the reference to check out before updating
+type GitCheckout struct {
+	Ref GitRepositoryRef `yaml:"ref"`
+}
+
+// CommitSpec describes the commits made by image automation
+type CommitSpec struct {
+	Author          CommitUser `yaml:"author"`
+	MessageTemplate string     `yaml:"messageTemplate,omitempty"`
+}
+
+// CommitUser is a Git commit author
+type CommitUser struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+}
+
+// PushSpec selects the branch image updates are pushed to
+type PushSpec struct {
+	Branch string `yaml:"branch"`
+}
+
+// UpdateStrategy selects the files image automation updates
+type UpdateStrategy struct {
+	Path     string `yaml:"path"`
+	Strategy string `yaml:"strategy"`
+}
+
+// HelmRepository is a Flux source pointing at a Helm chart repository
+type HelmRepository struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   ObjectMeta         `yaml:"metadata"`
+	Spec       HelmRepositorySpec `yaml:"spec"`
+}
+
+// HelmRepositorySpec is the desired state of a HelmRepository
+type HelmRepositorySpec struct {
+	Interval  string                `yaml:"interval"`
+	URL       string                `yaml:"url"`
+	SecretRef *LocalObjectReference `yaml:"secretRef,omitempty"`
+}
+
+// OCIRepository is a Flux source pointing at an OCI artifact
+type OCIRepository struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Spec       OCIRepositorySpec `yaml:"spec"`
+}
+
+// OCIRepositorySpec is the desired state of an OCIRepository
+type OCIRepositorySpec struct {
+	Interval      string                `yaml:"interval"`
+	URL           string                `yaml:"url"`
+	Ref           *OCIRepositoryRef     `yaml:"ref,omitempty"`
+	Insecure      bool                  `yaml:"insecure,omitempty"`
+	SecretRef     *LocalObjectReference `yaml:"secretRef,omitempty"`
+	LayerSelector *OCILayerSelector     `yaml:"layerSelector,omitempty"`
+}
+
+// OCILayerSelector selects the artifact layer to extract
+type OCILayerSelector struct {
+	MediaType string `yaml:"mediaType"`
+	Operation string `yaml:"operation,omitempty"`
+}
+
+// OCIRepositoryRef selects the artifact version to pull
+type OCIRepositoryRef struct {
+	Tag    string `yaml:"tag,omitempty"`
+	SemVer string `yaml:"semver,omitempty"`
+}
+
+// HelmRelease installs and upgrades a Helm chart
+type HelmRelease struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   ObjectMeta      `yaml:"metadata"`
+	Spec       HelmReleaseSpec `yaml:"spec"`
+}
+
+// HelmReleaseSpec is the desired state of a HelmRelease
+type HelmReleaseSpec struct {
+	Interval        string                         `yaml:"interval"`
+	Timeout         string                         `yaml:"timeout,omitempty"`
+	ReleaseName     string                         `yaml:"releaseName,omitempty"`
+	TargetNamespace string                         `yaml:"targetNamespace,omitempty"`
+	Chart           *HelmChartTemplate             `yaml:"chart,omitempty"`
+	ChartRef        *CrossNamespaceSourceReference `yaml:"chartRef,omitempty"`
+	DependsOn       []DependencyReference          `yaml:"dependsOn,omitempty"`
+	Install         *HelmInstall                   `yaml:"install,omitempty"`
+	Upgrade         *HelmUpgrade                   `yaml:"upgrade,omitempty"`
+	DriftDetection  *DriftDetection                `yaml:"driftDetection,omitempty"`
+	Values          map[string]interface{}         `yaml:"values,omitempty"`
+}
+
+// HelmChartTemplate describes the chart a HelmRelease is built from
+type HelmChartTemplate struct {
+	Spec HelmChartTemplateSpec `yaml:"spec"`
+}
+
+// HelmChartTemplateSpec selects a chart from a GitRepository or HelmRepository
+type HelmChartTemplateSpec struct {
+	Chart             string                        `yaml:"chart"`
+	Version           string                        `yaml:"version,omitempty"`
+	SourceRef         CrossNamespaceSourceReference `yaml:"sourceRef"`
+	Interval          string                        `yaml:"interval,omitempty"`
+	ReconcileStrategy string                        `yaml:"reconcileStrategy,omitempty"`
+}
+
+// HelmInstall configures how a HelmRelease is installed
+type HelmInstall struct {
+	CreateNamespace bool             `yaml:"createNamespace,omitempty"`
+	Remediation     *HelmRemediation `yaml:"remediation,omitempty"`
+}
+
+// HelmUpgrade configures how a HelmRelease is upgraded
+type HelmUpgrade struct {
+	CleanupOnFail bool             `yaml:"cleanupOnFail,omitempty"`
+	Remediation   *HelmRemediation `yaml:"remediation,omitempty"`
+}
+
+// HelmRemediation configures retries after a failed install or upgrade
+type HelmRemediation struct {
+	Retries              int    `yaml:"retries"`
+	RemediateLastFailure bool   `yaml:"remediateLastFailure,omitempty"`
+	Strategy             string `yaml:"strategy,omitempty"`
+}
+
+// DriftDetection configures how cluster changes to a release are handled
+type DriftDetection struct {
+	Mode string `yaml:"mode"`
+}
+
+// TypedObject is a Kubernetes object without a spec, such as a Namespace or ServiceAccount
+type TypedObject struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+}
+
+// RoleBinding grants a role to subjects within a namespace
+type RoleBinding struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	RoleRef    RoleRef    `yaml:"roleRef"`
+	Subjects   []Subject  `yaml:"subjects"`
+}
+
+// RoleRef references the role granted by a RoleBinding
+type RoleRef struct {
+	APIGroup string `yaml:"apiGroup"`
+	Kind     string `yaml:"kind"`
+	Name     string `yaml:"name"`
+}
+
+// Subject is a user, group or service account bound to a role
+type Subject struct {
+	APIGroup  string `yaml:"apiGroup,omitempty"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// Provider is a Flux notification target such as a Slack channel or webhook
+type Provider struct {
+	APIVersion string       `yaml:"apiVersion"`
+	Kind       string       `yaml:"kind"`
+	Metadata   ObjectMeta   `yaml:"metadata"`
+	Spec       ProviderSpec `yaml:"spec"`
+}
+
+// ProviderSpec is the desired state of a Provider
+type ProviderSpec struct {
+	Type      string                `yaml:"type"`
+	Channel   string                `yaml:"channel,omitempty"`
+	SecretRef *LocalObjectReference `yaml:"secretRef,omitempty"`
+}
+
+// Alert forwards events of Flux objects to a Provider
+type Alert struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       AlertSpec  `yaml:"spec"`
+}
+
+// AlertSpec is the desired state of an Alert
+type AlertSpec struct {
+	ProviderRef   LocalObjectReference            `yaml:"providerRef"`
+	EventSeverity string                          `yaml:"eventSeverity"`
+	EventSources  []CrossNamespaceSourceReference `yaml:"eventSources"`
+	EventMetadata map[string]string               `yaml:"eventMetadata,omitempty"`
+}
+
+// Secret is a Kubernetes Secret holding string data
+type Secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// Condition is a Kubernetes status condition
+type Condition struct {
+	Type               string `yaml:"type"`
+	Status             string `yaml:"status"`
+	Reason             string `yaml:"reason,omitempty"`
+	Message            string `yaml:"message,omitempty"`
+	LastTransitionTime string `yaml:"lastTransitionTime,omitempty"`
+}
+
+// Artifact is the output of a Flux source reconciliation
+type Artifact struct {
+	Revision       string `yaml:"revision,omitempty"`
+	LastUpdateTime string `yaml:"lastUpdateTime,omitempty"`
+}
+
+// Snapshot is a HelmRelease release history entry
+type Snapshot struct {
+	ChartVersion string `yaml:"chartVersion,omitempty"`
+	Status       string `yaml:"status,omitempty"`
+}
+
+// ObjectStatus holds the status fields shared by Flux sources and reconcilers
+type ObjectStatus struct {
+	Conditions             []Condition `yaml:"conditions,omitempty"`
+	Artifact               *Artifact   `yaml:"artifact,omitempty"`
+	LastAppliedRevision    string      `yaml:"lastAppliedRevision,omitempty"`
+	LastAttemptedRevision  string      `yaml:"lastAttemptedRevision,omitempty"`
+	LastHandledReconcileAt string      `yaml:"lastHandledReconcileAt,omitempty"`
+	History                []Snapshot  `yaml:"history,omitempty"`
+	Inventory              *Inventory  `yaml:"inventory,omitempty"`
+}
+
+// Inventory lists the objects applied by a Kustomization
+type Inventory struct {
+	Entries []InventoryEntry `yaml:"entries"`
+}
+
+// InventoryEntry identifies an applied object as namespace_name_group_kind
+type InventoryEntry struct {
+	ID      string `yaml:"id"`
+	Version string `yaml:"v"`
+}
+
+// Condition returns the condition of the given type, or nil when it is not set
+func (s *ObjectStatus) Condition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// Revision returns the last revision applied or fetched by the object
+func (s *ObjectStatus) Revision() string {
+	switch {
+	case s.LastAppliedRevision != "":
+		return s.LastAppliedRevision
+	case s.Artifact != nil && s.Artifact.Revision != "":
+		return s.Artifact.Revision
+	case len(s.History) > 0:
+		return s.History[0].ChartVersion
+	}
+	return ""
+}
+
+// LiveObject is a Flux object read from the cluster
+type LiveObject struct {
+	Kind     string     `yaml:"kind"`
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     struct {
+		Suspend   bool                          `yaml:"suspend,omitempty"`
+		SourceRef CrossNamespaceSourceReference `yaml:"sourceRef,omitempty"`
+		DependsOn []DependencyReference         `yaml:"dependsOn,omitempty"`
+	} `yaml:"spec"`
+	Status ObjectStatus `yaml:"status"`
+}
@@ -0,0 +1,225 @@
+package flux
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/spf13/cobra"
+)
+
+// uninstallCmd creates a command to tear down the Flux configuration generated by TroyOps
+func uninstallCmd() *cobra.Command {
+	var namespace string
+	var prune bool
+	var keepControllers bool
+	var dryRun bool
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove TroyOps Flux objects and the Flux controllers",
+		Long: `Suspend and delete the Kustomizations and GitRepositories generated by TroyOps, then
+remove the Flux controllers from the namespace.
+
+By default the workloads applied by the Kustomizations are orphaned and keep running.
+Use --prune to have Flux delete them together with the Kustomizations.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			uninstallFlux(namespace, prune, keepControllers, dryRun, yes)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Flux namespace (defaults to flux.namespace from the config)")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Delete the workloads applied by the Kustomizations instead of orphaning them")
+	cmd.Flags().BoolVar(&keepControllers, "keep-controllers", false, "Keep the Flux controllers installed")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the teardown plan without changing the cluster")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+// uninstallFlux prints the teardown plan and carries it out once confirmed
+func uninstallFlux(namespace string, prune, keepControllers, dryRun, yes bool) {
+	if namespace == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Println("Error loading project config:", err)
+			return
+		}
+		namespace = cfg.Flux.Namespace
+	}
+
+	selector := ManagedByLabel + "=" + ManagedByValue
+	var kustomizations, repositories []LiveObject
+	if err := kube.List(kustomizationResource.Resource, namespace, selector, &kustomizations); err != nil {
+		fmt.Println("Error listing Kustomizations:", err)
+		return
+	}
+	if err := kube.List(gitRepositoryResource.Resource, namespace, selector, &repositories); err != nil {
+		fmt.Println("Error listing GitRepositories:", err)
+		return
+	}
+
+	// Kustomizations applying these objects from Git, such as flux-system applying
+	// flux/applications, would re-create them once deleted, so they are suspended first
+	objects := append(append([]LiveObject{}, kustomizations...), repositories...)
+	parents := appliedBy(objects, namespace)
+
+	// Print the plan
+	workloads := "orphaned"
+	if prune {
+		workloads = "pruned"
+	}
+	fmt.Printf("Teardown plan for namespace %s:\n", namespace)
+	for _, p := range parents {
+		fmt.Printf("  - suspend Kustomization/%s in %s, which applies the objects below from Git and would re-create them\n", p.Name, p.Namespace)
+	}
+	for _, k := range kustomizations {
+		count := 0
+		if k.Status.Inventory != nil {
+			count = len(k.Status.Inventory.Entries)
+		}
+		fmt.Printf("  - suspend and delete Kustomization/%s (%d applied objects will be %s)\n", k.Metadata.Name, count, workloads)
+	}
+	for _, r := range repositories {
+		fmt.Printf("  - suspend and delete GitRepository/%s\n", r.Metadata.Name)
+	}
+	if !keepControllers {
+		fmt.Printf("  - remove the Flux controllers and CRDs from %s\n", namespace)
+	}
+	if len(kustomizations) == 0 && len(repositories) == 0 && keepControllers {
+		fmt.Println("  nothing to do")
+		return
+	}
+
+	if dryRun {
+		fmt.Println("Dry run: no changes made.")
+		return
+	}
+	if !yes && !confirm("Proceed with the teardown?") {
+		fmt.Println("Aborted.")
+		return
+	}
+
+	// Stop reconciliation before anything is removed
+	for _, p := range parents {
+		patch := map[string]interface{}{"spec": map[string]interface{}{"suspend": true}}
+		if err := kube.Patch(kustomizationResource.Resource, p.Namespace, p.Name, patch); err != nil {
+			fmt.Printf("Error suspending parent Kustomization/%s: %v\n", p.Name, err)
+			return
+		}
+		fmt.Printf("Suspended parent Kustomization/%s\n", p.Name)
+	}
+	for _, t := range append(targetsOf(kustomizationResource, kustomizations), targetsOf(gitRepositoryResource, repositories)...) {
+		patch := map[string]interface{}{"spec": map[string]interface{}{"suspend": true}}
+		if err := kube.Patch(t.res.Resource, namespace, t.obj.Metadata.Name, patch); err != nil {
+			fmt.Printf("Error suspending %s/%s: %v\n", t.res.Kind, t.obj.Metadata.Name, err)
+			return
+		}
+		fmt.Printf("Suspended %s/%s\n", t.res.Kind, t.obj.Metadata.Name)
+	}
+
+	// The kustomize-controller finalizer only garbage collects the inventory of
+	// Kustomizations that are pruned and not suspended when they are deleted
+	for _, k := range kustomizations {
+		spec := map[string]interface{}{"prune": false}
+		if prune {
+			spec = map[string]interface{}{"prune": true, "suspend": false}
+		}
+		if err := kube.Patch(kustomizationResource.Resource, namespace, k.Metadata.Name, map[string]interface{}{"spec": spec}); err != nil {
+			fmt.Printf("Error preparing Kustomization/%s for deletion: %v\n", k.Metadata.Name, err)
+			return
+		}
+		if _, err := kube.Kubectl("delete", kustomizationResource.Resource, k.Metadata.Name, "--namespace", namespace, "--wait"); err != nil {
+			fmt.Printf("Error deleting Kustomization/%s: %v\n", k.Metadata.Name, err)
+			return
+		}
+		fmt.Printf("Deleted Kustomization/%s (workloads %s)\n", k.Metadata.Name, workloads)
+	}
+	for _, r := range repositories {
+		if _, err := kube.Kubectl("delete", gitRepositoryResource.Resource, r.Metadata.Name, "--namespace", namespace, "--wait"); err != nil {
+			fmt.Printf("Error deleting GitRepository/%s: %v\n", r.Metadata.Name, err)
+			return
+		}
+		fmt.Printf("Deleted GitRepository/%s\n", r.Metadata.Name)
+	}
+
+	if !keepControllers {
+		if _, err := exec.LookPath("flux"); err != nil {
+			fmt.Println("Error: Flux CLI is not installed. Please install it to remove the controllers.")
+			fmt.Println("Installation instructions: https://fluxcd.io/docs/installation/")
+			return
+		}
+
+		fmt.Println("Removing Flux controllers...")
+		uninstallCmd := exec.Command("flux", "uninstall", "--namespace", namespace, "--silent")
+		uninstallCmd.Stdout = os.Stdout
+		uninstallCmd.Stderr = os.Stderr
+		if err := uninstallCmd.Run(); err != nil {
+			fmt.Println("Error removing Flux controllers:", err)
+			return
+		}
+	}
+
+	fmt.Println("Flux teardown completed successfully!")
+	if keepControllers {
+		for _, p := range parents {
+			fmt.Printf("Note: Kustomization/%s stays suspended; remove the TroyOps objects from its path in Git before resuming it.\n", p.Name)
+		}
+	}
+}
+
+// appliedBy returns the Kustomizations that apply any of objects, leaving out the objects themselves.
+// Objects without a namespace label were applied by a Kustomization in namespace.
+func appliedBy(objects []LiveObject, namespace string) []ObjectMeta {
+	own := map[string]bool{}
+	for _, obj := range objects {
+		if obj.Kind == kustomizationResource.Kind {
+			own[obj.Metadata.Name] = true
+		}
+	}
+
+	var parents []ObjectMeta
+	seen := map[string]bool{}
+	for _, obj := range objects {
+		name := obj.Metadata.Labels[AppliedByNameLabel]
+		if name == "" {
+			continue
+		}
+		ns := obj.Metadata.Labels[AppliedByNamespaceLabel]
+		if ns == "" {
+			ns = namespace
+		}
+		if (ns == namespace && own[name]) || seen[ns+"/"+name] {
+			continue
+		}
+		seen[ns+"/"+name] = true
+		parents = append(parents, ObjectMeta{Name: name, Namespace: ns})
+	}
+	return parents
+}
+
+// targetsOf pairs live objects with their resource type
+func targetsOf(res fluxResource, objects []LiveObject) []target {
+	targets := make([]target, 0, len(objects))
+	for _, obj := range objects {
+		targets = append(targets, target{res, obj})
+	}
+	return targets
+}
+
+// confirm asks a yes/no question on the terminal and returns true for yes
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
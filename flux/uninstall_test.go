@@ -0,0 +1,57 @@
+package flux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAppliedBy(t *testing.T) {
+	applied := func(kind, name, parent, parentNamespace string) LiveObject {
+		obj := LiveObject{Kind: kind, Metadata: ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if parent != "" {
+			obj.Metadata.Labels[AppliedByNameLabel] = parent
+		}
+		if parentNamespace != "" {
+			obj.Metadata.Labels[AppliedByNamespaceLabel] = parentNamespace
+		}
+		return obj
+	}
+
+	tests := []struct {
+		name    string
+		objects []LiveObject
+		want    []ObjectMeta
+	}{
+		{
+			name:    "applied with kubectl",
+			objects: []LiveObject{applied("Kustomization", "troyops-dev", "", "")},
+		},
+		{
+			name: "applied by flux-system",
+			objects: []LiveObject{
+				applied("Kustomization", "troyops-dev", "flux-system", "flux-system"),
+				applied("GitRepository", "troyops-repo", "flux-system", "flux-system"),
+			},
+			want: []ObjectMeta{{Name: "flux-system", Namespace: "flux-system"}},
+		},
+		{
+			name: "parent deleted as well",
+			objects: []LiveObject{
+				applied("Kustomization", "troyops-apps", "", ""),
+				applied("Kustomization", "troyops-dev", "troyops-apps", "flux-system"),
+			},
+		},
+		{
+			name:    "parent in another namespace",
+			objects: []LiveObject{applied("Kustomization", "troyops-dev", "troyops-dev", "platform")},
+			want:    []ObjectMeta{{Name: "troyops-dev", Namespace: "platform"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appliedBy(tt.objects, "flux-system"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appliedBy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
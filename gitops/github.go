@@ -0,0 +1,113 @@
+package gitops
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// github opens pull requests through the GitHub REST and GraphQL APIs
+type github struct {
+	client *apiClient
+	// repository is owner/repository
+	repository string
+}
+
+// githubPullRequest is the part of a GitHub pull request troyops uses
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	NodeID  string `json:"node_id"`
+}
+
+func (p githubPullRequest) pullRequest() *pullRequest {
+	return &pullRequest{Number: p.Number, URL: p.HTMLURL, ID: p.NodeID}
+}
+
+// enableAutoMergeMutation turns on auto-merge, which only exists in the GraphQL API
+const enableAutoMergeMutation = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`
+
+func (g *github) find(head, base string) (*pullRequest, error) {
+	owner := g.repository[:strings.Index(g.repository, "/")]
+	query := url.Values{"state": {"open"}, "head": {owner + ":" + head}, "base": {base}}
+
+	var pulls []githubPullRequest
+	if err := g.client.do("GET", "repos/"+g.repository+"/pulls?"+query.Encode(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return pulls[0].pullRequest(), nil
+}
+
+func (g *github) create(spec pullRequestSpec) (*pullRequest, error) {
+	body := map[string]interface{}{"title": spec.Title, "head": spec.Head, "base": spec.Base, "body": spec.Body}
+	var pull githubPullRequest
+	if err := g.client.do("POST", "repos/"+g.repository+"/pulls", body, &pull); err != nil {
+		return nil, err
+	}
+	pr := pull.pullRequest()
+	return pr, g.addLabels(pr, spec.Labels)
+}
+
+func (g *github) update(pr *pullRequest, spec pullRequestSpec) error {
+	body := map[string]interface{}{"title": spec.Title, "body": spec.Body}
+	if err := g.client.do("PATCH", fmt.Sprintf("repos/%s/pulls/%d", g.repository, pr.Number), body, nil); err != nil {
+		return err
+	}
+	return g.addLabels(pr, spec.Labels)
+}
+
+// addLabels labels the pull request, creating missing labels
+func (g *github) addLabels(pr *pullRequest, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	body := map[string]interface{}{"labels": labels}
+	return g.client.do("POST", fmt.Sprintf("repos/%s/issues/%d/labels", g.repository, pr.Number), body, nil)
+}
+
+func (g *github) autoMerge(pr *pullRequest, spec pullRequestSpec) error {
+	body := map[string]interface{}{
+		"query":     enableAutoMergeMutation,
+		"variables": map[string]string{"id": pr.ID, "method": strings.ToUpper(spec.MergeMethod)},
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := g.client.do("POST", g.graphqlPath(), body, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("enabling auto-merge: %s", resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// graphqlPath returns the GraphQL endpoint. GitHub Enterprise serves it at /api/graphql
+// rather than under the REST API root /api/v3.
+func (g *github) graphqlPath() string {
+	if g.client.cli == "" && g.client.host != "github.com" {
+		return "https://" + g.client.host + "/api/graphql"
+	}
+	return "graphql"
+}
+
+func (g *github) comment(number int, marker, body string) error {
+	var comments []comment
+	if err := g.client.do("GET", fmt.Sprintf("repos/%s/issues/%d/comments?per_page=100", g.repository, number), nil, &comments); err != nil {
+		return err
+	}
+	payload := map[string]interface{}{"body": body}
+	if existing := findComment(comments, marker); existing != nil {
+		return g.client.do("PATCH", fmt.Sprintf("repos/%s/issues/comments/%d", g.repository, existing.ID), payload, nil)
+	}
+	return g.client.do("POST", fmt.Sprintf("repos/%s/issues/%d/comments", g.repository, number), payload, nil)
+}
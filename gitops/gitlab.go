@@ -0,0 +1,87 @@
+package gitops
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// gitlab opens merge requests through the GitLab REST API
+type gitlab struct {
+	client *apiClient
+	// project is the URL-encoded project path
+	project string
+}
+
+// gitlabMergeRequest is the part of a GitLab merge request troyops uses
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+func (m gitlabMergeRequest) pullRequest() *pullRequest {
+	return &pullRequest{Number: m.IID, URL: m.WebURL}
+}
+
+func (g *gitlab) find(head, base string) (*pullRequest, error) {
+	query := url.Values{"state": {"opened"}, "source_branch": {head}, "target_branch": {base}}
+
+	var mrs []gitlabMergeRequest
+	if err := g.client.do("GET", "projects/"+g.project+"/merge_requests?"+query.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return mrs[0].pullRequest(), nil
+}
+
+func (g *gitlab) create(spec pullRequestSpec) (*pullRequest, error) {
+	body := map[string]interface{}{
+		"source_branch":        spec.Head,
+		"target_branch":        spec.Base,
+		"title":                spec.Title,
+		"description":          spec.Body,
+		"labels":               strings.Join(spec.Labels, ","),
+		"remove_source_branch": true,
+		"squash":               spec.MergeMethod == "squash",
+	}
+	var mr gitlabMergeRequest
+	if err := g.client.do("POST", "projects/"+g.project+"/merge_requests", body, &mr); err != nil {
+		return nil, err
+	}
+	return mr.pullRequest(), nil
+}
+
+func (g *gitlab) update(pr *pullRequest, spec pullRequestSpec) error {
+	body := map[string]interface{}{
+		"title":       spec.Title,
+		"description": spec.Body,
+		"add_labels":  strings.Join(spec.Labels, ","),
+	}
+	return g.client.do("PUT", fmt.Sprintf("projects/%s/merge_requests/%d", g.project, pr.Number), body, nil)
+}
+
+// autoMerge sets the merge request to merge when its pipeline succeeds.
+// GitLab merges or rebases according to the project's merge method; only squashing is per request.
+func (g *gitlab) autoMerge(pr *pullRequest, spec pullRequestSpec) error {
+	body := map[string]interface{}{
+		"merge_when_pipeline_succeeds": true,
+		"squash":                       spec.MergeMethod == "squash",
+		"should_remove_source_branch":  true,
+	}
+	return g.client.do("PUT", fmt.Sprintf("projects/%s/merge_requests/%d/merge", g.project, pr.Number), body, nil)
+}
+
+func (g *gitlab) comment(number int, marker, body string) error {
+	notes := fmt.Sprintf("projects/%s/merge_requests/%d/notes", g.project, number)
+	var comments []comment
+	if err := g.client.do("GET", notes+"?per_page=100", nil, &comments); err != nil {
+		return err
+	}
+	payload := map[string]interface{}{"body": body}
+	if existing := findComment(comments, marker); existing != nil {
+		return g.client.do("PUT", fmt.Sprintf("%s/%d", notes, existing.ID), payload, nil)
+	}
+	return g.client.do("POST", notes, payload, nil)
+}
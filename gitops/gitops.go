@@ -0,0 +1,20 @@
+package gitops
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// GitOpsCmd groups the commands CI pipelines use to change the GitOps repository
+func GitOpsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gitops",
+		Short: "Propose changes to the GitOps repository",
+		Long: `Change the GitOps repository through reviewed pull requests instead of direct pushes,
+so that deployments work with protected branches and leave an audit trail.`,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(pullRequestCmd())
+
+	return cmd
+}
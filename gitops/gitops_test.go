@@ -0,0 +1,150 @@
+package gitops
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want manifest.Image
+	}{
+		{"docker.io/acme/demo:1.2.3", manifest.Image{Name: "docker.io/acme/demo", Tag: "1.2.3"}},
+		{"registry.local:5000/demo@sha256:abc", manifest.Image{Name: "registry.local:5000/demo", Digest: "sha256:abc"}},
+		{"ghcr.io/acme/demo:sha-1234567@sha256:abc", manifest.Image{Name: "ghcr.io/acme/demo", Tag: "sha-1234567", Digest: "sha256:abc"}},
+		{"demo", manifest.Image{Name: "demo"}},
+	}
+	for _, tt := range tests {
+		if got := manifest.ParseImage(tt.ref); got != tt.want {
+			t.Errorf("ParseImage(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestSetImage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kustomization.yaml")
+	original := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ../../base
+images:
+  - name: docker.io/acme/demo
+    newTag: sha-1111111
+`
+	if err := os.WriteFile(file, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	image := manifest.Image{Name: "docker.io/acme/demo", Tag: "sha-2222222", Digest: "sha256:abc"}
+	changed, err := setImage(file, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("setImage reported no change")
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ../../base
+images:
+  - name: docker.io/acme/demo
+    digest: sha256:abc
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if changed, err := setImage(file, image); err != nil || changed {
+		t.Errorf("setting the same image again: changed=%v, err=%v", changed, err)
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		url  string
+		want remote
+	}{
+		{"https://github.com/acme/gitops.git", remote{"github.com", "acme/gitops"}},
+		{"git@gitlab.com:acme/platform/gitops.git", remote{"gitlab.com", "acme/platform/gitops"}},
+		{"ssh://git@github.example.com:2222/acme/gitops", remote{"github.example.com", "acme/gitops"}},
+	}
+	for _, tt := range tests {
+		got, err := parseRemote(tt.url)
+		if err != nil {
+			t.Errorf("parseRemote(%q): %v", tt.url, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseRemote(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubPullRequest(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/gitops/pulls":
+			if r.URL.Query().Get("head") != "acme:troyops/deploy-dev" {
+				t.Errorf("unexpected head filter %q", r.URL.Query().Get("head"))
+			}
+			w.Write([]byte(`[]`))
+		case "POST /repos/acme/gitops/pulls":
+			w.Write([]byte(`{"number": 7, "html_url": "https://github.com/acme/gitops/pull/7", "node_id": "PR_7"}`))
+		case "POST /repos/acme/gitops/issues/7/labels":
+			w.Write([]byte(`[]`))
+		case "POST /graphql":
+			var body struct {
+				Variables map[string]string `json:"variables"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Variables["id"] != "PR_7" || body.Variables["method"] != "SQUASH" {
+				t.Errorf("unexpected auto-merge variables %v", body.Variables)
+			}
+			w.Write([]byte(`{"data": {}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	g := &github{
+		client:     &apiClient{host: "github.com", baseURL: server.URL + "/", header: "Authorization", token: "Bearer secret"},
+		repository: "acme/gitops",
+	}
+	spec := pullRequestSpec{Head: "troyops/deploy-dev", Base: "main", Title: "Deploy", Labels: []string{"deploy"}, MergeMethod: "squash"}
+
+	pr, err := g.find(spec.Head, spec.Base)
+	if err != nil || pr != nil {
+		t.Fatalf("find: pr=%v, err=%v", pr, err)
+	}
+	if pr, err = g.create(spec); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 7 || pr.URL != "https://github.com/acme/gitops/pull/7" {
+		t.Errorf("unexpected pull request %+v", pr)
+	}
+	if err := g.autoMerge(pr, spec); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 4 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
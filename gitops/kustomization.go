@@ -0,0 +1,117 @@
+package gitops
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jefftrojan/troyops/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationFiles are the file names Kustomize looks for in a directory
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// findKustomization returns the kustomization file of an overlay directory
+func findKustomization(overlay string) (string, error) {
+	for _, name := range kustomizationFiles {
+		path := filepath.Join(overlay, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no kustomization file in %s", overlay)
+}
+
+// setImage points the images entry of a kustomization at an image, like kustomize edit set image.
+// A digest replaces newTag so the overlay is pinned to exactly one image. It reports whether the file changed.
+func setImage(kustomizationFile string, image manifest.Image) (bool, error) {
+	data, err := os.ReadFile(kustomizationFile)
+	if err != nil {
+		return false, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false, fmt.Errorf("%s is not a YAML mapping", kustomizationFile)
+	}
+	root := doc.Content[0]
+
+	images := mappingValue(root, "images")
+	if images == nil {
+		images = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, scalarNode("images"), images)
+	}
+
+	var entry *yaml.Node
+	for _, item := range images.Content {
+		if n := mappingValue(item, "name"); n != nil && n.Value == image.Name {
+			entry = item
+			break
+		}
+	}
+	if entry == nil {
+		entry = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(entry, "name", image.Name)
+		images.Content = append(images.Content, entry)
+	}
+
+	if image.Digest != "" {
+		setMappingValue(entry, "digest", image.Digest)
+		deleteMappingKey(entry, "newTag")
+	} else {
+		setMappingValue(entry, "newTag", image.Tag)
+		deleteMappingKey(entry, "digest")
+	}
+
+	out, err := manifest.Encode(&doc)
+	if err != nil {
+		return false, err
+	}
+	if string(out) == string(data) {
+		return false, nil
+	}
+	return true, os.WriteFile(kustomizationFile, out, 0644)
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to a string value in a mapping node
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	if existing := mappingValue(mapping, key); existing != nil {
+		existing.Kind = yaml.ScalarNode
+		existing.Tag = "!!str"
+		existing.Value = value
+		return
+	}
+	mapping.Content = append(mapping.Content, scalarNode(key), scalarNode(value))
+}
+
+// deleteMappingKey removes key from a mapping node
+func deleteMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// scalarNode returns a string scalar node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,305 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// MergeMethods lists the supported --merge-method values
+var MergeMethods = []string{"squash", "merge", "rebase"}
+
+// Commits are authored like the direct pushes of the generated pipelines
+const (
+	commitAuthor = "Flux CD"
+	commitEmail  = "flux@example.com"
+)
+
+// prOptions holds the flags of troyops gitops pr
+type prOptions struct {
+	environment string
+	overlay     string
+	image       string
+	tag         string
+	base        string
+	branch      string
+	title       string
+	labels      []string
+	autoMerge   bool
+	mergeMethod string
+	provider    string
+	remote      string
+}
+
+// pullRequestCmd creates a command opening a pull request that deploys an image to an environment
+func pullRequestCmd() *cobra.Command {
+	var opts prOptions
+
+	cmd := &cobra.Command{
+		Use:   "pr",
+		Short: "Open a pull request updating the image of an environment overlay",
+		Long: `Point the images entry of an environment's kustomization at a new image and propose
+the change as a pull request (GitHub) or merge request (GitLab) instead of pushing it to
+the deploy branch.
+
+The change is committed on --branch (troyops/deploy-<environment> by default), reset
+from the base branch and force-pushed on every run, so each environment has at most one
+open pull request and it always deploys the latest image. An existing pull request is
+updated rather than duplicated. Nothing is opened when the overlay already deploys the image.
+
+--image takes repository[:tag][@digest]; with a digest the overlay is pinned to it.
+The provider is detected from the remote URL. The API token is read from GITHUB_TOKEN
+(or GH_TOKEN) and GITLAB_TOKEN; without one the authenticated gh or glab CLI is used.
+--auto-merge merges the pull request once its required checks pass.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !openPullRequest(opts) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&opts.environment, "environment", "e", "", "Environment to deploy (required)")
+	cmd.Flags().StringVar(&opts.overlay, "overlay", "", "Overlay directory to update (defaults to the environment's overlay)")
+	cmd.Flags().StringVar(&opts.image, "image", "", "Image to deploy, repository[:tag][@digest] (required)")
+	cmd.Flags().StringVar(&opts.tag, "tag", "", "Tag shown in the pull request title (defaults to the tag of --image)")
+	cmd.Flags().StringVar(&opts.base, "base", "", "Branch Flux syncs from (defaults to the repository branch)")
+	cmd.Flags().StringVar(&opts.branch, "branch", "", "Branch the change is pushed to (defaults to troyops/deploy-<environment>)")
+	cmd.Flags().StringVar(&opts.title, "title", "", "Pull request title (defaults to Deploy <app> <tag> to <environment>)")
+	cmd.Flags().StringArrayVar(&opts.labels, "label", nil, "Label to add to the pull request (repeatable)")
+	cmd.Flags().BoolVar(&opts.autoMerge, "auto-merge", false, "Merge the pull request once its checks pass")
+	cmd.Flags().StringVar(&opts.mergeMethod, "merge-method", "squash", "How the pull request is merged ("+strings.Join(MergeMethods, ", ")+")")
+	cmd.Flags().StringVar(&opts.provider, "provider", "auto", "Git provider (auto, github, gitlab)")
+	cmd.Flags().StringVar(&opts.remote, "remote", "origin", "Git remote of the GitOps repository")
+	cmd.MarkFlagRequired("environment")
+	cmd.MarkFlagRequired("image")
+
+	return cmd
+}
+
+// openPullRequest commits the overlay change on the deploy branch and opens or updates its pull request.
+// It returns false on failure.
+func openPullRequest(opts prOptions) bool {
+	if !validMergeMethod(opts.mergeMethod) {
+		fmt.Printf("Error: unsupported merge method: %s (available: %s)\n", opts.mergeMethod, strings.Join(MergeMethods, ", "))
+		return false
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return false
+	}
+	if opts.overlay == "" {
+		env, err := cfg.Environment(opts.environment)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return false
+		}
+		opts.overlay = env.Overlay
+	}
+
+	image := manifest.ParseImage(opts.image)
+	if image.Tag == "" && image.Digest == "" {
+		fmt.Printf("Error: image %s has neither a tag nor a digest\n", opts.image)
+		return false
+	}
+	if opts.tag == "" {
+		opts.tag = image.Tag
+	}
+	if opts.tag == "" {
+		opts.tag = image.Digest
+	}
+	if opts.base == "" {
+		opts.base = cfg.Repository.Branch
+	}
+	if opts.branch == "" {
+		opts.branch = "troyops/deploy-" + opts.environment
+	}
+	if opts.title == "" {
+		opts.title = fmt.Sprintf("Deploy %s %s to %s", cfg.App, opts.tag, opts.environment)
+	}
+
+	// Resolve the provider before changing anything, so that missing credentials fail early
+	p, err := remoteProvider(opts.remote, opts.provider)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	// Commit the change on top of the base branch in a temporary worktree, leaving the
+	// current checkout, its branch and any uncommitted changes alone
+	fmt.Printf("Updating %s to %s on branch %s...\n", opts.overlay, image, opts.branch)
+	if _, err := git("fetch", opts.remote, opts.base); err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	prefix, err := git("rev-parse", "--show-prefix")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	worktree, err := addWorktree("FETCH_HEAD")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	defer removeWorktree(worktree)
+
+	file, err := findKustomization(filepath.Join(worktree, prefix, opts.overlay))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	changed, err := setImage(file, image)
+	if err != nil {
+		fmt.Printf("Error updating %s: %v\n", file, err)
+		return false
+	}
+	if !changed {
+		fmt.Printf("%s already deploys %s on %s, no pull request needed\n", opts.environment, image, opts.base)
+		return true
+	}
+	file, err = filepath.Rel(worktree, file)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if _, err := git("-C", worktree, "add", file); err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if _, err := git(append(identity(), "-C", worktree, "commit", "-m", opts.title)...); err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if _, err := git("-C", worktree, "push", "--force", opts.remote, "HEAD:refs/heads/"+opts.branch); err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	spec := pullRequestSpec{
+		Head:        opts.branch,
+		Base:        opts.base,
+		Title:       opts.title,
+		Body:        pullRequestBody(opts, file, image),
+		Labels:      opts.labels,
+		MergeMethod: opts.mergeMethod,
+	}
+	pr, err := p.find(spec.Head, spec.Base)
+	if err != nil {
+		fmt.Println("Error looking up existing pull requests:", err)
+		return false
+	}
+	if pr != nil {
+		if err := p.update(pr, spec); err != nil {
+			fmt.Printf("Error updating pull request %s: %v\n", pr.URL, err)
+			return false
+		}
+		fmt.Printf("Updated pull request %s\n", pr.URL)
+	} else {
+		if pr, err = p.create(spec); err != nil {
+			fmt.Println("Error opening pull request:", err)
+			return false
+		}
+		fmt.Printf("Opened pull request %s\n", pr.URL)
+	}
+
+	if opts.autoMerge {
+		if err := p.autoMerge(pr, spec); err != nil {
+			fmt.Println("Error enabling auto-merge:", err)
+			return false
+		}
+		fmt.Printf("Auto-merge enabled (%s)\n", opts.mergeMethod)
+	}
+	return true
+}
+
+// pullRequestBody describes the deployment for reviewers
+func pullRequestBody(opts prOptions, file string, image manifest.Image) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deploys `%s` to **%s** by updating `%s`.\n\n", image, opts.environment, filepath.ToSlash(file))
+	if url := buildURL(); url != "" {
+		fmt.Fprintf(&b, "Built by %s\n\n", url)
+	}
+	fmt.Fprintf(&b, "Opened by `troyops gitops pr`. Flux applies the change once it is merged into `%s`.\n", opts.base)
+	return b.String()
+}
+
+// buildURL links the CI run that built the image, when known
+func buildURL() string {
+	switch {
+	case os.Getenv("GITHUB_RUN_ID") != "":
+		return fmt.Sprintf("%s/%s/actions/runs/%s", os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID"))
+	case os.Getenv("CI_PIPELINE_URL") != "":
+		return os.Getenv("CI_PIPELINE_URL")
+	case os.Getenv("BUILD_URL") != "":
+		return os.Getenv("BUILD_URL")
+	case os.Getenv("CIRCLE_BUILD_URL") != "":
+		return os.Getenv("CIRCLE_BUILD_URL")
+	}
+	return ""
+}
+
+// validMergeMethod reports whether method is supported
+func validMergeMethod(method string) bool {
+	for _, m := range MergeMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// identity sets the commit author when Git has none configured, as in fresh CI containers
+func identity() []string {
+	if out, err := exec.Command("git", "config", "user.email").Output(); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return nil
+	}
+	return []string{"-c", "user.name=" + commitAuthor, "-c", "user.email=" + commitEmail}
+}
+
+// addWorktree checks out commit, detached, in a new temporary worktree and returns its path
+func addWorktree(commit string) (string, error) {
+	dir, err := os.MkdirTemp("", "troyops-pr-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := git("worktree", "add", "--detach", dir, commit); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeWorktree deletes a worktree created by addWorktree
+func removeWorktree(dir string) {
+	if _, err := git("worktree", "remove", "--force", dir); err != nil {
+		fmt.Println("Warning:", err)
+		os.RemoveAll(dir)
+		git("worktree", "prune")
+	}
+}
+
+// git runs git in the current directory and returns its trimmed standard output
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,271 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pullRequest is an open pull request (GitHub) or merge request (GitLab)
+type pullRequest struct {
+	Number int
+	URL    string
+	// ID is the GraphQL node ID of a GitHub pull request
+	ID string
+}
+
+// pullRequestSpec describes the pull request to open or update
+type pullRequestSpec struct {
+	Head        string
+	Base        string
+	Title       string
+	Body        string
+	Labels      []string
+	MergeMethod string
+}
+
+// provider opens pull requests on a Git hosting service
+type provider interface {
+	// find returns the open pull request from head to base, or nil
+	find(head, base string) (*pullRequest, error)
+	create(spec pullRequestSpec) (*pullRequest, error)
+	update(pr *pullRequest, spec pullRequestSpec) error
+	// autoMerge merges the pull request once its checks pass
+	autoMerge(pr *pullRequest, spec pullRequestSpec) error
+	// comment edits the comment of pull request number containing marker, or adds one
+	comment(number int, marker, body string) error
+}
+
+// comment is a pull request comment (GitHub) or note (GitLab)
+type comment struct {
+	ID   int    `json:"id"`
+	Body string `json:"body"`
+}
+
+// findComment returns the first comment containing marker, or nil
+func findComment(comments []comment, marker string) *comment {
+	for i := range comments {
+		if strings.Contains(comments[i].Body, marker) {
+			return &comments[i]
+		}
+	}
+	return nil
+}
+
+// Providers supported by troyops gitops pr
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// remote is a Git remote split into host and repository path
+type remote struct {
+	Host string
+	// Path is owner/repository on GitHub and the full project path on GitLab
+	Path string
+}
+
+// parseRemote parses HTTPS, SSH and scp-style Git URLs
+func parseRemote(rawURL string) (remote, error) {
+	var host, path string
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return remote{}, err
+		}
+		host, path = u.Hostname(), u.Path
+	} else if colon := strings.Index(rawURL, ":"); colon >= 0 {
+		// scp-style: git@host:owner/repository.git
+		host, path = rawURL[:colon], rawURL[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return remote{}, fmt.Errorf("cannot determine the repository from remote URL %s", rawURL)
+	}
+	return remote{Host: host, Path: path}, nil
+}
+
+// detectProvider guesses the hosting service from the remote host
+func detectProvider(r remote) (string, error) {
+	switch {
+	case strings.Contains(r.Host, "github"):
+		return ProviderGitHub, nil
+	case strings.Contains(r.Host, "gitlab"):
+		return ProviderGitLab, nil
+	}
+	return "", fmt.Errorf("cannot detect the Git provider of %s, set --provider (%s, %s)", r.Host, ProviderGitHub, ProviderGitLab)
+}
+
+// remoteProvider returns the client of the hosting service behind a Git remote of the current
+// repository. An empty or auto providerName detects the service from the remote URL.
+func remoteProvider(remoteName, providerName string) (provider, error) {
+	remoteURL, err := git("config", "--get", "remote."+remoteName+".url")
+	if err != nil {
+		return nil, err
+	}
+	repo, err := parseRemote(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	if providerName == "" || providerName == "auto" {
+		if providerName, err = detectProvider(repo); err != nil {
+			return nil, err
+		}
+	}
+	return newProvider(providerName, repo)
+}
+
+// Comment posts body on a pull request of the repository behind a Git remote. The earlier
+// comment containing marker is edited instead, so that repeated runs keep a single comment.
+func Comment(remoteName, providerName string, number int, marker, body string) error {
+	p, err := remoteProvider(remoteName, providerName)
+	if err != nil {
+		return err
+	}
+	return p.comment(number, marker, body)
+}
+
+// newProvider returns the client of a hosting service. It authenticates with the
+// provider's token variable, or through the gh or glab CLI when the variable is unset.
+func newProvider(name string, r remote) (provider, error) {
+	switch name {
+	case ProviderGitHub:
+		client, err := newAPIClient(r.Host, "gh", []string{"GITHUB_TOKEN", "GH_TOKEN"})
+		if err != nil {
+			return nil, err
+		}
+		client.header, client.token = "Authorization", "Bearer "+client.token
+		client.baseURL = "https://api.github.com/"
+		if r.Host != "github.com" {
+			client.baseURL = "https://" + r.Host + "/api/v3/"
+		}
+		return &github{client: client, repository: r.Path}, nil
+	case ProviderGitLab:
+		client, err := newAPIClient(r.Host, "glab", []string{"GITLAB_TOKEN"})
+		if err != nil {
+			return nil, err
+		}
+		client.header = "PRIVATE-TOKEN"
+		client.baseURL = "https://" + r.Host + "/api/v4/"
+		return &gitlab{client: client, project: url.PathEscape(r.Path)}, nil
+	}
+	return nil, fmt.Errorf("unsupported provider: %s (available: %s, %s)", name, ProviderGitHub, ProviderGitLab)
+}
+
+// apiClient sends JSON requests to a provider's REST API, over HTTPS with a token or through its CLI
+type apiClient struct {
+	host    string
+	baseURL string
+	header  string
+	token   string
+	// cli is the provider CLI used when no token is set
+	cli string
+}
+
+// newAPIClient reads the token from the first set variable, falling back to the CLI
+func newAPIClient(host, cli string, tokenVariables []string) (*apiClient, error) {
+	for _, name := range tokenVariables {
+		if token := os.Getenv(name); token != "" {
+			return &apiClient{host: host, token: token}, nil
+		}
+	}
+	if _, err := exec.LookPath(cli); err != nil {
+		return nil, fmt.Errorf("set %s or install and authenticate the %s CLI", strings.Join(tokenVariables, " or "), cli)
+	}
+	return &apiClient{host: host, cli: cli}, nil
+}
+
+// do sends a request to path, relative to the API root unless absolute, and decodes the JSON response into out when not nil
+func (c *apiClient) do(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	var data []byte
+	var err error
+	if c.cli != "" {
+		data, err = c.doCLI(method, path, payload)
+	} else {
+		data, err = c.doHTTP(method, path, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding response of %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// doHTTP sends the request with the token
+func (c *apiClient) doHTTP(method, path string, payload []byte) ([]byte, error) {
+	endpoint := path
+	if !strings.HasPrefix(path, "https://") {
+		endpoint = c.baseURL + path
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(c.header, c.token)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// doCLI sends the request with gh api or glab api, which use the CLI's stored credentials
+func (c *apiClient) doCLI(method, path string, payload []byte) ([]byte, error) {
+	args := []string{"api", "--hostname", c.host, "--method", method, path}
+	if payload != nil {
+		args = append(args, "--input", "-")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.cli, args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s api %s %s: %s", c.cli, method, path, msg)
+	}
+	return stdout.Bytes(), nil
+}
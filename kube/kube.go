@@ -0,0 +1,151 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kubectl runs kubectl with the given arguments and returns its standard output
+func Kubectl(args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return nil, fmt.Errorf("kubectl is not installed")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("kubectl %s: %s", strings.Join(args, " "), msg)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// List fetches the objects of a resource type and decodes them into out,
+// which must be a pointer to a slice. An empty namespace lists all namespaces.
+func List(resource, namespace, selector string, out interface{}) error {
+	args := []string{"get", resource, "-o", "yaml"}
+	if namespace == "" {
+		args = append(args, "--all-namespaces")
+	} else {
+		args = append(args, "--namespace", namespace)
+	}
+	if selector != "" {
+		args = append(args, "--selector", selector)
+	}
+
+	data, err := Kubectl(args...)
+	if err != nil {
+		return err
+	}
+
+	var list struct {
+		Items yaml.Node `yaml:"items"`
+	}
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("decoding %s: %v", resource, err)
+	}
+	if list.Items.Kind == 0 {
+		return nil
+	}
+	return list.Items.Decode(out)
+}
+
+// Get fetches a single object and decodes it into out
+func Get(resource, namespace, name string, out interface{}) error {
+	data, err := Kubectl("get", resource, name, "--namespace", namespace, "-o", "yaml")
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// Annotate sets annotations on an object, overwriting existing values.
+// An empty value removes the annotation.
+func Annotate(resource, namespace, name string, annotations map[string]string) error {
+	args := []string{"annotate", resource, name, "--namespace", namespace, "--overwrite"}
+	for key, value := range annotations {
+		if value == "" {
+			args = append(args, key+"-")
+		} else {
+			args = append(args, key+"="+value)
+		}
+	}
+	_, err := Kubectl(args...)
+	return err
+}
+
+// Patch applies a JSON merge patch to an object
+func Patch(resource, namespace, name string, patch interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = Kubectl("patch", resource, name, "--namespace", namespace, "--type", "merge", "--patch", string(data))
+	return err
+}
+
+// Watch streams the objects of a resource type as they change and calls handle with
+// each object as JSON. It returns when kubectl exits or handle returns an error.
+func Watch(resource, namespace string, handle func([]byte) error) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("kubectl is not installed")
+	}
+
+	args := []string{"get", resource, "--watch", "-o", "json"}
+	if namespace == "" {
+		args = append(args, "--all-namespaces")
+	} else {
+		args = append(args, "--namespace", namespace)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// kubectl writes one JSON document per change
+	decoder := json.NewDecoder(stdout)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("decoding %s: %v", resource, err)
+		}
+		if err := handle(raw); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("kubectl %s: %s", strings.Join(args, " "), msg)
+	}
+	return nil
+}
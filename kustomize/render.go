@@ -0,0 +1,35 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+// Build runs kubectl kustomize for the given path and returns the rendered YAML
+func Build(path string) ([]byte, error) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return nil, fmt.Errorf("kubectl is not installed")
+	}
+
+	var stdout, stderr bytes.Buffer
+	buildCmd := exec.Command("kubectl", "kustomize", path)
+	buildCmd.Stdout = &stdout
+	buildCmd.Stderr = &stderr
+	if err := buildCmd.Run(); err != nil {
+		return nil, fmt.Errorf("kubectl kustomize failed: %v: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Render builds the kustomization at path and returns the decoded objects
+func Render(path string) ([]manifest.Object, error) {
+	data, err := Build(path)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Decode(data)
+}
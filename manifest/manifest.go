@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Object is a decoded Kubernetes object
+type Object map[string]interface{}
+
+// APIVersion returns the apiVersion of the object
+func (o Object) APIVersion() string {
+	s, _ := o["apiVersion"].(string)
+	return s
+}
+
+// Kind returns the kind of the object
+func (o Object) Kind() string {
+	s, _ := o["kind"].(string)
+	return s
+}
+
+// Metadata returns the metadata map of the object, creating it if missing
+func (o Object) Metadata() map[string]interface{} {
+	metadata, ok := o["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		o["metadata"] = metadata
+	}
+	return metadata
+}
+
+// Name returns metadata.name of the object
+func (o Object) Name() string {
+	s, _ := o.Metadata()["name"].(string)
+	return s
+}
+
+// Namespace returns metadata.namespace of the object
+func (o Object) Namespace() string {
+	s, _ := o.Metadata()["namespace"].(string)
+	return s
+}
+
+// ID returns a Kind/name identifier for the object
+func (o Object) ID() string {
+	return fmt.Sprintf("%s/%s", o.Kind(), o.Name())
+}
+
+// Decode parses a multi-document YAML stream into objects, skipping empty documents
+func Decode(data []byte) ([]Object, error) {
+	var objects []Object
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		// Decode into a plain map so that nested mappings are map[string]interface{} as well
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		obj := Object(doc)
+
+		// Expand List kinds into their items
+		if items, ok := obj["items"].([]interface{}); ok && obj.Kind() == "List" {
+			for _, item := range items {
+				if m, ok := item.(map[string]interface{}); ok {
+					objects = append(objects, Object(m))
+				}
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// Encode renders values as a multi-document YAML stream with two-space indentation
+func Encode(values ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, v := range values {
+		if err := encoder.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Image is a container image reference split into name, tag and digest
+type Image struct {
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// ParseImage splits name[:tag][@digest], leaving registry ports in the name
+func ParseImage(ref string) Image {
+	var image Image
+	if at := strings.Index(ref, "@"); at >= 0 {
+		image.Digest = ref[at+1:]
+		ref = ref[:at]
+	}
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		image.Tag = ref[colon+1:]
+		ref = ref[:colon]
+	}
+	image.Name = ref
+	return image
+}
+
+// String returns the reference Kustomize deploys, preferring the digest
+func (i Image) String() string {
+	if i.Digest != "" {
+		return i.Name + "@" + i.Digest
+	}
+	return i.Name + ":" + i.Tag
+}
@@ -0,0 +1,595 @@
+package parity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jefftrojan/troyops/charts"
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/kustomize"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// deliveryMetadata lists labels and annotations added by the delivery tool itself,
+// which never affect the workload and are ignored when comparing objects
+var deliveryMetadata = []string{
+	"helm.sh/chart",
+	"app.kubernetes.io/managed-by",
+	"app.kubernetes.io/version",
+	"meta.helm.sh/release-name",
+	"meta.helm.sh/release-namespace",
+}
+
+// quantityPattern matches Kubernetes resource quantities such as 0.5, 500m or 512Mi
+var quantityPattern = regexp.MustCompile(`^([+-]?[0-9]+(?:\.[0-9]+)?)(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+
+// quantitySuffixes maps quantity suffixes to their multipliers
+var quantitySuffixes = map[string]float64{
+	"":   1,
+	"m":  1e-3,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// workloadKinds are the kinds whose spec.template holds a pod template
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"ReplicaSet":  true,
+	"Job":         true,
+}
+
+// difference describes a single field that differs between the Helm and Kustomize output
+type difference struct {
+	Path      string
+	Helm      interface{}
+	Kustomize interface{}
+}
+
+// ParityCmd defines the command for comparing the Helm chart with the Kustomize overlays
+func ParityCmd() *cobra.Command {
+	var environment string
+	var chartPath string
+	var valuesFiles []string
+	var release string
+	var namespace string
+	var overlay string
+	var ignore []string
+
+	cmd := &cobra.Command{
+		Use:   "parity",
+		Short: "Compare Helm chart output with Kustomize overlays",
+		Long: `Render the Helm chart and the Kustomize overlay for an environment and report the
+semantic differences between the resulting objects.
+
+Objects are matched by kind and name. Delivery-specific metadata (Helm labels and
+annotations) is ignored, defaults such as port protocols are filled in, and resource
+quantities are compared by value so that 0.5 and 500m are considered equal.
+The command exits with a non-zero status when the two outputs diverge.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !checkParity(environment, chartPath, valuesFiles, release, namespace, overlay, ignore) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVarP(&environment, "environment", "e", "dev", "Environment to compare (dev, staging, prod)")
+	cmd.Flags().StringVarP(&chartPath, "chart", "c", filepath.Join("charts", "troyops-helm-chart"), "Path to the Helm chart")
+	cmd.Flags().StringSliceVarP(&valuesFiles, "values", "f", nil, "Helm values files for the environment (defaults to <chart>/values-{environment}.yaml if present)")
+	cmd.Flags().StringVar(&release, "release", "troyops", "Helm release name used when rendering the chart")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace both outputs are installed into (defaults to the environment's namespace in troyops.yaml, or its name)")
+	cmd.Flags().StringVarP(&overlay, "overlay", "o", "", "Kustomize overlay to compare (defaults to kustomize/overlays/{environment})")
+	cmd.Flags().StringSliceVar(&ignore, "ignore", nil, "Field paths to ignore, e.g. metadata.labels")
+
+	return cmd
+}
+
+// checkParity renders both delivery paths and prints their differences.
+// It returns true when the outputs are equivalent.
+func checkParity(environment, chartPath string, valuesFiles []string, release, namespace, overlay string, ignore []string) bool {
+	if overlay == "" {
+		overlay = filepath.Join("kustomize", "overlays", environment)
+	}
+	if namespace == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Println("Error loading project config:", err)
+			return false
+		}
+		namespace = environment
+		if env, err := cfg.Environment(environment); err == nil && env.Namespace != "" {
+			namespace = env.Namespace
+		}
+	}
+	if len(valuesFiles) == 0 {
+		envValues := filepath.Join(chartPath, fmt.Sprintf("values-%s.yaml", environment))
+		if _, err := os.Stat(envValues); err == nil {
+			valuesFiles = []string{envValues}
+		}
+	}
+
+	if len(valuesFiles) > 0 {
+		fmt.Printf("Comparing chart %s (values: %s) with overlay %s...\n", chartPath, strings.Join(valuesFiles, ", "), overlay)
+	} else {
+		fmt.Printf("Comparing chart %s with overlay %s...\n", chartPath, overlay)
+	}
+
+	helmObjects, err := charts.Render(chartPath, release, namespace, valuesFiles)
+	if err != nil {
+		fmt.Println("Error rendering Helm chart:", err)
+		return false
+	}
+	kustomizeObjects, err := kustomize.Render(overlay)
+	if err != nil {
+		fmt.Println("Error rendering Kustomize overlay:", err)
+		return false
+	}
+
+	// Objects without a namespace are installed into the target namespace by both tools
+	normalizeAll(helmObjects, namespace)
+	normalizeAll(kustomizeObjects, namespace)
+
+	pairs, onlyHelm, onlyKustomize := pairObjects(helmObjects, kustomizeObjects)
+
+	equivalent := len(onlyHelm) == 0 && len(onlyKustomize) == 0
+	for _, pair := range pairs {
+		helmObj, kustomizeObj := pair[0], pair[1]
+
+		var diffs []difference
+		if helmObj.Name() != kustomizeObj.Name() {
+			diffs = append(diffs, difference{Path: "metadata.name", Helm: helmObj.Name(), Kustomize: kustomizeObj.Name()})
+		}
+		compare("", withoutName(helmObj), withoutName(kustomizeObj), &diffs)
+		diffs = filterIgnored(diffs, ignore)
+		if len(diffs) == 0 {
+			continue
+		}
+
+		equivalent = false
+		fmt.Printf("\n~ %s\n", kustomizeObj.ID())
+		for _, d := range diffs {
+			fmt.Printf("    %s: helm=%s kustomize=%s\n", d.Path, formatValue(d.Helm), formatValue(d.Kustomize))
+		}
+	}
+	for _, obj := range onlyHelm {
+		fmt.Printf("\n- %s is only rendered by the Helm chart\n", obj.ID())
+	}
+	for _, obj := range onlyKustomize {
+		fmt.Printf("\n+ %s is only rendered by the Kustomize overlay\n", obj.ID())
+	}
+
+	if equivalent {
+		fmt.Printf("Helm chart and Kustomize overlay are equivalent for %s (%d objects)\n", environment, len(pairs))
+		return true
+	}
+
+	fmt.Printf("\nHelm chart and Kustomize overlay diverge for %s\n", environment)
+	return false
+}
+
+// pairObjects matches objects by kind and name. Objects left over after matching by
+// name are paired by kind when exactly one object of that kind remains on each side.
+func pairObjects(helmObjects, kustomizeObjects []manifest.Object) ([][2]manifest.Object, []manifest.Object, []manifest.Object) {
+	var pairs [][2]manifest.Object
+
+	remaining := map[string]manifest.Object{}
+	for _, obj := range kustomizeObjects {
+		remaining[obj.ID()] = obj
+	}
+
+	var leftHelm []manifest.Object
+	for _, obj := range helmObjects {
+		if match, ok := remaining[obj.ID()]; ok {
+			pairs = append(pairs, [2]manifest.Object{obj, match})
+			delete(remaining, obj.ID())
+			continue
+		}
+		leftHelm = append(leftHelm, obj)
+	}
+
+	helmByKind := groupByKind(leftHelm)
+	var leftKustomize []manifest.Object
+	for _, obj := range kustomizeObjects {
+		if _, ok := remaining[obj.ID()]; ok {
+			leftKustomize = append(leftKustomize, obj)
+		}
+	}
+	kustomizeByKind := groupByKind(leftKustomize)
+
+	var onlyHelm, onlyKustomize []manifest.Object
+	for _, obj := range leftHelm {
+		if len(helmByKind[obj.Kind()]) == 1 && len(kustomizeByKind[obj.Kind()]) == 1 {
+			pairs = append(pairs, [2]manifest.Object{obj, kustomizeByKind[obj.Kind()][0]})
+			continue
+		}
+		onlyHelm = append(onlyHelm, obj)
+	}
+	for _, obj := range leftKustomize {
+		if len(helmByKind[obj.Kind()]) == 1 && len(kustomizeByKind[obj.Kind()]) == 1 {
+			continue
+		}
+		onlyKustomize = append(onlyKustomize, obj)
+	}
+
+	return pairs, onlyHelm, onlyKustomize
+}
+
+// groupByKind groups objects by their kind
+func groupByKind(objects []manifest.Object) map[string][]manifest.Object {
+	groups := map[string][]manifest.Object{}
+	for _, obj := range objects {
+		groups[obj.Kind()] = append(groups[obj.Kind()], obj)
+	}
+	return groups
+}
+
+// withoutName returns a shallow copy of the object without metadata.name,
+// which is compared separately since objects may be paired by kind
+func withoutName(obj manifest.Object) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	for k, v := range obj.Metadata() {
+		if k != "name" {
+			metadata[k] = v
+		}
+	}
+
+	copied := map[string]interface{}{}
+	for k, v := range obj {
+		copied[k] = v
+	}
+	copied["metadata"] = metadata
+	return copied
+}
+
+// normalizeAll strips delivery metadata and fills in API defaults on all objects
+func normalizeAll(objects []manifest.Object, namespace string) {
+	// Collect named container ports so that named Service targetPorts can be resolved
+	portNames := map[string]interface{}{}
+	for _, obj := range objects {
+		for _, container := range podContainers(obj) {
+			for _, p := range asList(container["ports"]) {
+				port, ok := p.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if name, ok := port["name"].(string); ok {
+					portNames[name] = port["containerPort"]
+				}
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		metadata := obj.Metadata()
+		if namespace != "" && metadata["namespace"] == nil {
+			metadata["namespace"] = namespace
+		}
+		for _, meta := range []interface{}{metadata, dig(obj, "spec", "template", "metadata")} {
+			for _, field := range []string{"labels", "annotations"} {
+				values, ok := dig(meta, field).(map[string]interface{})
+				if !ok {
+					continue
+				}
+				for _, key := range deliveryMetadata {
+					delete(values, key)
+				}
+			}
+		}
+
+		for _, container := range podContainers(obj) {
+			for _, p := range asList(container["ports"]) {
+				if port, ok := p.(map[string]interface{}); ok && port["protocol"] == nil {
+					port["protocol"] = "TCP"
+				}
+			}
+			if container["imagePullPolicy"] == nil {
+				image, _ := container["image"].(string)
+				if strings.HasSuffix(image, ":latest") || !strings.Contains(filepath.Base(image), ":") {
+					container["imagePullPolicy"] = "Always"
+				} else {
+					container["imagePullPolicy"] = "IfNotPresent"
+				}
+			}
+		}
+
+		if obj.Kind() == "Service" {
+			spec, ok := obj["spec"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if spec["type"] == nil {
+				spec["type"] = "ClusterIP"
+			}
+			for _, p := range asList(spec["ports"]) {
+				port, ok := p.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if port["protocol"] == nil {
+					port["protocol"] = "TCP"
+				}
+				if port["targetPort"] == nil {
+					port["targetPort"] = port["port"]
+				}
+				if name, ok := port["targetPort"].(string); ok && portNames[name] != nil {
+					port["targetPort"] = portNames[name]
+				}
+			}
+		}
+	}
+}
+
+// podContainers returns the containers of a workload's pod template
+func podContainers(obj manifest.Object) []map[string]interface{} {
+	if !workloadKinds[obj.Kind()] {
+		return nil
+	}
+
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		for _, c := range asList(dig(obj, "spec", "template", "spec", field)) {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
+
+// compare records the differences between a and b at path
+func compare(path string, a, b interface{}, diffs *[]difference) {
+	a, b = prune(a), prune(b)
+
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := map[string]bool{}
+		for k := range aMap {
+			keys[k] = true
+		}
+		for k := range bMap {
+			keys[k] = true
+		}
+		for _, k := range sortedKeys(keys) {
+			compare(joinPath(path, k), aMap[k], bMap[k], diffs)
+		}
+		return
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		compareLists(path, aList, bList, diffs)
+		return
+	}
+
+	if !equalValues(a, b) {
+		*diffs = append(*diffs, difference{Path: path, Helm: a, Kustomize: b})
+	}
+}
+
+// compareLists compares lists of named or numbered items by key and other lists by position
+func compareLists(path string, a, b []interface{}, diffs *[]difference) {
+	keyField := listKey(a, b)
+	if keyField == "" {
+		for i := 0; i < len(a) || i < len(b); i++ {
+			var aItem, bItem interface{}
+			if i < len(a) {
+				aItem = a[i]
+			}
+			if i < len(b) {
+				bItem = b[i]
+			}
+			compare(fmt.Sprintf("%s[%d]", path, i), aItem, bItem, diffs)
+		}
+		return
+	}
+
+	aItems, aOrder := indexList(a, keyField)
+	bItems, bOrder := indexList(b, keyField)
+
+	// A single unmatched item on each side is compared directly, e.g. a renamed container
+	var aLeft, bLeft []string
+	for _, k := range aOrder {
+		if _, ok := bItems[k]; !ok {
+			aLeft = append(aLeft, k)
+		}
+	}
+	for _, k := range bOrder {
+		if _, ok := aItems[k]; !ok {
+			bLeft = append(bLeft, k)
+		}
+	}
+	if len(aLeft) == 1 && len(bLeft) == 1 {
+		bItems[aLeft[0]] = bItems[bLeft[0]]
+		delete(bItems, bLeft[0])
+		bOrder = removeKey(bOrder, bLeft[0])
+	}
+
+	seen := map[string]bool{}
+	for _, k := range append(aOrder, bOrder...) {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		compare(fmt.Sprintf("%s[%s]", path, k), aItems[k], bItems[k], diffs)
+	}
+}
+
+// listKey returns the field identifying items in both lists, if any
+func listKey(a, b []interface{}) string {
+	for _, field := range []string{"name", "containerPort", "port", "key"} {
+		found := len(a)+len(b) > 0
+		for _, item := range append(append([]interface{}{}, a...), b...) {
+			m, ok := item.(map[string]interface{})
+			if !ok || m[field] == nil {
+				found = false
+				break
+			}
+		}
+		if found {
+			return field
+		}
+	}
+	return ""
+}
+
+// indexList maps list items by the string form of their key field
+func indexList(list []interface{}, keyField string) (map[string]interface{}, []string) {
+	items := map[string]interface{}{}
+	var order []string
+	for _, item := range list {
+		key := fmt.Sprint(item.(map[string]interface{})[keyField])
+		items[key] = item
+		order = append(order, key)
+	}
+	return items, order
+}
+
+// removeKey returns keys without the given key
+func removeKey(keys []string, key string) []string {
+	var result []string
+	for _, k := range keys {
+		if k != key {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// prune treats empty maps and lists as absent values
+func prune(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+	}
+	return v
+}
+
+// equalValues compares scalars by their string form, falling back to resource quantity values
+func equalValues(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	aStr, bStr := fmt.Sprint(a), fmt.Sprint(b)
+	if aStr == bStr {
+		return true
+	}
+
+	aQty, aOK := parseQuantity(aStr)
+	bQty, bOK := parseQuantity(bStr)
+	return aOK && bOK && aQty == bQty
+}
+
+// parseQuantity converts a Kubernetes resource quantity into its numeric value
+func parseQuantity(s string) (float64, bool) {
+	match := quantityPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return value * quantitySuffixes[match[2]], true
+}
+
+// filterIgnored drops differences whose path starts with one of the ignored paths
+func filterIgnored(diffs []difference, ignore []string) []difference {
+	var result []difference
+	for _, d := range diffs {
+		ignored := false
+		for _, prefix := range ignore {
+			if d.Path == prefix || strings.HasPrefix(d.Path, prefix+".") || strings.HasPrefix(d.Path, prefix+"[") {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// formatValue renders a value for display
+func formatValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "<missing>"
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// dig returns the value at the given field path, or nil when any field is missing
+func dig(v interface{}, fields ...string) interface{} {
+	if obj, ok := v.(manifest.Object); ok {
+		v = map[string]interface{}(obj)
+	}
+	for _, field := range fields {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[field]
+	}
+	return v
+}
+
+// asList returns v as a list, or nil when it is not one
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// joinPath appends a field to a dotted path, quoting fields that contain dots
+func joinPath(path, field string) string {
+	if strings.Contains(field, ".") {
+		return fmt.Sprintf("%s[%q]", path, field)
+	}
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
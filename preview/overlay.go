@@ -0,0 +1,192 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+// kustomization is the generated overlay of a preview environment
+type kustomization struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Namespace  string      `yaml:"namespace"`
+	NameSuffix string      `yaml:"nameSuffix"`
+	Resources  []string    `yaml:"resources"`
+	Labels     []labelSet  `yaml:"labels"`
+	Images     []imageSet  `yaml:"images,omitempty"`
+	Patches    []jsonPatch `yaml:"patches,omitempty"`
+}
+
+// labelSet adds labels to every object without changing selectors
+type labelSet struct {
+	Pairs map[string]string `yaml:"pairs"`
+}
+
+// imageSet overrides the image of the source overlay
+type imageSet struct {
+	Name   string `yaml:"name"`
+	NewTag string `yaml:"newTag,omitempty"`
+	Digest string `yaml:"digest,omitempty"`
+}
+
+// jsonPatch is a JSON 6902 patch of one object, selected by its name in the source overlay
+type jsonPatch struct {
+	Target patchTarget `yaml:"target"`
+	Patch  string      `yaml:"patch"`
+}
+
+type patchTarget struct {
+	Kind string `yaml:"kind"`
+	Name string `yaml:"name"`
+}
+
+// patchOperation is a JSON 6902 operation
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// overlay is a preview environment derived from the overlay of an environment
+type overlay struct {
+	Name          string
+	Dir           string
+	Kustomization kustomization
+	// URL is the address of the first Ingress host, empty without Ingresses
+	URL string
+}
+
+// newOverlay builds the preview overlay name in dir on top of source, whose rendered objects are given.
+// Ingress hosts get the preview name appended to their first label and, when domain is set, move under it.
+func newOverlay(name, dir, source string, objects []manifest.Object, domain, image string) (*overlay, error) {
+	base, err := filepath.Rel(dir, source)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &overlay{
+		Name: name,
+		Dir:  dir,
+		Kustomization: kustomization{
+			APIVersion: "kustomize.config.k8s.io/v1beta1",
+			Kind:       "Kustomization",
+			Namespace:  name,
+			NameSuffix: "-" + name,
+			Resources:  []string{filepath.ToSlash(base)},
+			Labels:     []labelSet{{Pairs: map[string]string{Label: name}}},
+		},
+	}
+
+	if image != "" {
+		ref := manifest.ParseImage(image)
+		if ref.Tag == "" && ref.Digest == "" {
+			return nil, fmt.Errorf("image %s has neither a tag nor a digest", image)
+		}
+		o.Kustomization.Images = []imageSet{{Name: ref.Name, NewTag: ref.Tag, Digest: ref.Digest}}
+	}
+
+	for _, obj := range objects {
+		if obj.Kind() != "Ingress" {
+			continue
+		}
+		ops, url := rewriteIngressHosts(obj, name, domain)
+		if len(ops) == 0 {
+			continue
+		}
+		patch, err := json.Marshal(ops)
+		if err != nil {
+			return nil, err
+		}
+		o.Kustomization.Patches = append(o.Kustomization.Patches, jsonPatch{
+			Target: patchTarget{Kind: "Ingress", Name: obj.Name()},
+			Patch:  string(patch),
+		})
+		if o.URL == "" {
+			o.URL = url
+		}
+	}
+	return o, nil
+}
+
+// rewriteIngressHosts returns the operations moving the hosts of an Ingress to the preview,
+// and the URL of its first rule
+func rewriteIngressHosts(ingress manifest.Object, name, domain string) ([]patchOperation, string) {
+	spec, _ := ingress["spec"].(map[string]interface{})
+
+	var ops []patchOperation
+	tlsHosts := map[string]bool{}
+	tls, _ := spec["tls"].([]interface{})
+	for i, entry := range tls {
+		entry, _ := entry.(map[string]interface{})
+		hosts, _ := entry["hosts"].([]interface{})
+		for j, host := range hosts {
+			if host, ok := host.(string); ok && !strings.HasPrefix(host, "*") {
+				rewritten := previewHost(host, name, domain)
+				tlsHosts[rewritten] = true
+				ops = append(ops, patchOperation{"replace", fmt.Sprintf("/spec/tls/%d/hosts/%d", i, j), rewritten})
+			}
+		}
+	}
+
+	var url string
+	rules, _ := spec["rules"].([]interface{})
+	for i, rule := range rules {
+		rule, _ := rule.(map[string]interface{})
+		host, _ := rule["host"].(string)
+		if host == "" || strings.HasPrefix(host, "*") {
+			continue
+		}
+		rewritten := previewHost(host, name, domain)
+		ops = append(ops, patchOperation{"replace", fmt.Sprintf("/spec/rules/%d/host", i), rewritten})
+		if url == "" {
+			scheme := "http"
+			if tlsHosts[rewritten] {
+				scheme = "https"
+			}
+			url = scheme + "://" + rewritten
+		}
+	}
+	return ops, url
+}
+
+// previewHost appends the preview name to the first label of host, so that
+// app.example.com becomes app-pr-42.example.com, or app-pr-42.<domain> with a domain
+func previewHost(host, name, domain string) string {
+	label, rest, _ := strings.Cut(host, ".")
+	if domain != "" {
+		rest = domain
+	}
+	if rest == "" {
+		return label + "-" + name
+	}
+	return label + "-" + name + "." + rest
+}
+
+// write writes the kustomization and the namespace manifest of the preview
+func (o *overlay) write(namespace manifest.Object) error {
+	if err := os.MkdirAll(o.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := manifest.Encode(o.Kustomization)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(o.Dir, "kustomization.yaml"), data, 0644); err != nil {
+		return err
+	}
+	if data, err = manifest.Encode(namespace); err != nil {
+		return err
+	}
+	return os.WriteFile(o.namespaceFile(), data, 0644)
+}
+
+// namespaceFile is the namespace manifest, applied before the overlay and kept out of it
+// so that the name suffix does not apply to it
+func (o *overlay) namespaceFile() string {
+	return filepath.Join(o.Dir, "namespace.yaml")
+}
@@ -0,0 +1,65 @@
+package preview
+
+import (
+	"testing"
+
+	"github.com/jefftrojan/troyops/manifest"
+)
+
+func TestPreviewHost(t *testing.T) {
+	tests := []struct {
+		host, domain, want string
+	}{
+		{"app.example.com", "", "app-pr-42.example.com"},
+		{"app.example.com", "preview.example.com", "app-pr-42.preview.example.com"},
+		{"localhost", "", "localhost-pr-42"},
+	}
+	for _, tt := range tests {
+		if got := previewHost(tt.host, "pr-42", tt.domain); got != tt.want {
+			t.Errorf("previewHost(%q, %q) = %q, want %q", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestNewOverlay(t *testing.T) {
+	objects, err := manifest.Decode([]byte(`apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  tls:
+    - hosts: [app.example.com]
+  rules:
+    - host: "*.example.com"
+    - host: app.example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := newOverlay("pr-7", ".troyops/previews/pr-7", "kustomize/overlays/dev", objects, "", "ghcr.io/acme/demo@sha256:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := o.Kustomization
+	if k.Namespace != "pr-7" || k.NameSuffix != "-pr-7" {
+		t.Errorf("namespace %q and name suffix %q", k.Namespace, k.NameSuffix)
+	}
+	if want := "../../../kustomize/overlays/dev"; len(k.Resources) != 1 || k.Resources[0] != want {
+		t.Errorf("resources = %v, want [%s]", k.Resources, want)
+	}
+	if len(k.Images) != 1 || k.Images[0] != (imageSet{Name: "ghcr.io/acme/demo", Digest: "sha256:abc"}) {
+		t.Errorf("images = %+v", k.Images)
+	}
+	want := `[{"op":"replace","path":"/spec/tls/0/hosts/0","value":"app-pr-7.example.com"},{"op":"replace","path":"/spec/rules/1/host","value":"app-pr-7.example.com"}]`
+	if len(k.Patches) != 1 || k.Patches[0].Patch != want || k.Patches[0].Target.Name != "web" {
+		t.Errorf("patches = %+v", k.Patches)
+	}
+	if o.URL != "https://app-pr-7.example.com" {
+		t.Errorf("URL = %q", o.URL)
+	}
+
+	if _, err := newOverlay("pr-7", ".troyops/previews/pr-7", "kustomize/overlays/dev", nil, "", "ghcr.io/acme/demo"); err == nil {
+		t.Error("expected an error for an image without tag or digest")
+	}
+}
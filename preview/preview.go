@@ -0,0 +1,375 @@
+package preview
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jefftrojan/troyops/config"
+	"github.com/jefftrojan/troyops/gitops"
+	"github.com/jefftrojan/troyops/kube"
+	"github.com/jefftrojan/troyops/kustomize"
+	"github.com/jefftrojan/troyops/manifest"
+	"github.com/spf13/cobra"
+)
+
+// Label marks the namespace and objects of a preview environment with its name, pr-<number>
+const Label = "troyops.io/preview"
+
+// Annotations on the namespace of a preview environment
+const (
+	expiresAtAnnotation = "troyops.io/preview-expires-at"
+	urlAnnotation       = "troyops.io/preview-url"
+	sourceAnnotation    = "troyops.io/preview-source"
+)
+
+// commentMarker identifies the pull request comment of a preview environment, so that it is edited in place
+const commentMarker = "<!-- troyops-preview -->"
+
+// Dir is where the transient overlays of preview environments are written, relative to the repository
+var Dir = filepath.Join(".troyops", "previews")
+
+// commentOptions selects where the preview URL is posted
+type commentOptions struct {
+	enabled  bool
+	remote   string
+	provider string
+}
+
+// previewNamespace is the part of a preview namespace troyops preview gc reads
+type previewNamespace struct {
+	Metadata struct {
+		Name        string            `yaml:"name"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+}
+
+// upOptions holds the flags of troyops preview up
+type upOptions struct {
+	pr      int
+	from    string
+	image   string
+	domain  string
+	ttl     time.Duration
+	timeout time.Duration
+	comment commentOptions
+}
+
+// PreviewCmd manages ephemeral preview environments of pull requests
+func PreviewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Manage ephemeral preview environments of pull requests",
+		Long: `Deploy each pull request to its own namespace, pr-<number>, from the overlay of an
+environment (dev by default), and remove it when the pull request is closed or its
+time to live runs out.
+
+The preview overlay is generated in .troyops/previews/pr-<number>. It renders the source
+overlay into the pr-<number> namespace with a -pr-<number> name suffix, so that cluster-scoped
+objects do not collide, and labels every object with troyops.io/preview=pr-<number>.
+Ingress hosts get -pr-<number> appended to their first label (app.example.com becomes
+app-pr-<number>.example.com) or, with --domain, move under a wildcard preview domain.`,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(upCmd())
+	cmd.AddCommand(downCmd())
+	cmd.AddCommand(gcCmd())
+
+	return cmd
+}
+
+// upCmd creates a command deploying the preview environment of a pull request
+func upCmd() *cobra.Command {
+	var opts upOptions
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Create or update the preview environment of a pull request",
+		Long: `Generate the preview overlay of a pull request, apply it to the cluster and wait for its
+Deployments to become available. Running it again updates the environment and extends
+its expiry by --ttl.
+
+With --comment the URL is posted on the pull request, editing the earlier comment of the
+preview rather than adding one per push. The token is read from GITHUB_TOKEN or
+GITLAB_TOKEN, as for troyops gitops pr.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !up(opts) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().IntVar(&opts.pr, "pr", 0, "Pull request number (required)")
+	cmd.Flags().StringVar(&opts.from, "from", "dev", "Environment whose overlay the preview is created from")
+	cmd.Flags().StringVar(&opts.image, "image", "", "Image built from the pull request, repository[:tag][@digest]")
+	cmd.Flags().StringVar(&opts.domain, "domain", "", "Wildcard DNS domain preview hosts are moved under, e.g. preview.example.com")
+	cmd.Flags().DurationVar(&opts.ttl, "ttl", 72*time.Hour, "How long the preview is kept without updates before troyops preview gc removes it")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 5*time.Minute, "How long to wait for Deployments to become available")
+	addCommentFlags(cmd, &opts.comment)
+	cmd.MarkFlagRequired("pr")
+
+	return cmd
+}
+
+// downCmd creates a command removing the preview environment of a pull request
+func downCmd() *cobra.Command {
+	var pr int
+	var comment commentOptions
+
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Remove the preview environment of a pull request",
+		Long: `Delete the namespace of a pull request's preview environment, the cluster-scoped objects
+labelled with it and its local overlay. Removing a preview that does not exist succeeds.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !down(pr, comment) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().IntVar(&pr, "pr", 0, "Pull request number (required)")
+	addCommentFlags(cmd, &comment)
+	cmd.MarkFlagRequired("pr")
+
+	return cmd
+}
+
+// gcCmd creates a command removing expired preview environments
+func gcCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove preview environments whose time to live has run out",
+		Long: `Find the preview namespaces in the cluster and remove those past the expiry set by
+troyops preview up --ttl. Run it on a schedule to clean up previews of pull requests
+whose close event was missed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !collectGarbage(dryRun) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	// Add flags
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List expired previews without removing them")
+
+	return cmd
+}
+
+// addCommentFlags adds the flags posting to the pull request
+func addCommentFlags(cmd *cobra.Command, opts *commentOptions) {
+	cmd.Flags().BoolVar(&opts.enabled, "comment", false, "Post the status of the preview on the pull request")
+	cmd.Flags().StringVar(&opts.remote, "remote", "origin", "Git remote of the repository the pull request belongs to")
+	cmd.Flags().StringVar(&opts.provider, "provider", "auto", "Git provider (auto, github, gitlab)")
+}
+
+// previewName returns the name of the preview environment of a pull request
+func previewName(pr int) string {
+	return fmt.Sprintf("pr-%d", pr)
+}
+
+// up deploys the preview environment of a pull request and returns false on failure
+func up(opts upOptions) bool {
+	if opts.pr <= 0 {
+		fmt.Println("Error: --pr must be a pull request number")
+		return false
+	}
+	name := previewName(opts.pr)
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading project config:", err)
+		return false
+	}
+	env, err := cfg.Environment(opts.from)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	fmt.Printf("Creating preview environment %s from %s...\n", name, env.Overlay)
+	objects, err := kustomize.Render(env.Overlay)
+	if err != nil {
+		fmt.Printf("Error rendering %s: %v\n", env.Overlay, err)
+		return false
+	}
+	o, err := newOverlay(name, filepath.Join(Dir, name), env.Overlay, objects, opts.domain, opts.image)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	expiresAt := time.Now().Add(opts.ttl).UTC().Format(time.RFC3339)
+	namespace := manifest.Object{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]interface{}{Label: name},
+			"annotations": map[string]interface{}{
+				expiresAtAnnotation: expiresAt,
+				urlAnnotation:       o.URL,
+				sourceAnnotation:    env.Name,
+			},
+		},
+	}
+	if err := o.write(namespace); err != nil {
+		fmt.Println("Error writing preview overlay:", err)
+		return false
+	}
+	fmt.Printf("Wrote preview overlay %s\n", o.Dir)
+
+	if _, err := kube.Kubectl("apply", "-f", o.namespaceFile()); err != nil {
+		fmt.Println("Error creating namespace:", err)
+		return false
+	}
+	out, err := kube.Kubectl("apply", "-k", o.Dir)
+	if err != nil {
+		fmt.Println("Error applying preview overlay:", err)
+		return false
+	}
+	fmt.Print(string(out))
+
+	for _, obj := range objects {
+		if obj.Kind() == "Deployment" {
+			fmt.Printf("Waiting for Deployments in %s to become available...\n", name)
+			if _, err := kube.Kubectl("wait", "deployment", "--all", "--namespace", name, "--for", "condition=Available", "--timeout", opts.timeout.String()); err != nil {
+				fmt.Println("Error:", err)
+				return false
+			}
+			break
+		}
+	}
+
+	fmt.Printf("Preview environment %s is ready", name)
+	if o.URL != "" {
+		fmt.Printf(" at %s", o.URL)
+	}
+	fmt.Printf(", expires at %s\n", expiresAt)
+
+	if opts.comment.enabled {
+		body := fmt.Sprintf("%s\nPreview environment **%s** is deployed", commentMarker, name)
+		if o.URL != "" {
+			body += " at " + o.URL
+		}
+		body += fmt.Sprintf(".\n\nCreated from the %s overlay", env.Name)
+		if opts.image != "" {
+			body += fmt.Sprintf(" with `%s`", opts.image)
+		}
+		body += fmt.Sprintf(". It is removed when the pull request is closed, or at %s unless updated.\n", expiresAt)
+		return postComment(opts.pr, opts.comment, body)
+	}
+	return true
+}
+
+// down removes the preview environment of a pull request and returns false on failure
+func down(pr int, comment commentOptions) bool {
+	if pr <= 0 {
+		fmt.Println("Error: --pr must be a pull request number")
+		return false
+	}
+	if !remove(previewName(pr)) {
+		return false
+	}
+	if comment.enabled {
+		body := fmt.Sprintf("%s\nPreview environment **%s** was removed.\n", commentMarker, previewName(pr))
+		return postComment(pr, comment, body)
+	}
+	return true
+}
+
+// remove deletes the namespace and cluster-scoped objects of a preview and its local overlay
+func remove(name string) bool {
+	fmt.Printf("Removing preview environment %s...\n", name)
+	if _, err := kube.Kubectl("delete", "namespace", name, "--ignore-not-found", "--wait=false"); err != nil {
+		fmt.Println("Error deleting namespace:", err)
+		return false
+	}
+
+	// Cluster-scoped objects, such as ClusterRoles, are not deleted with the namespace
+	out, err := kube.Kubectl("api-resources", "--namespaced=false", "--verbs=list,delete", "-o", "name")
+	if err != nil {
+		fmt.Println("Error listing cluster-scoped resource types:", err)
+		return false
+	}
+	var resources []string
+	for _, resource := range strings.Fields(string(out)) {
+		if resource != "namespaces" {
+			resources = append(resources, resource)
+		}
+	}
+	if len(resources) > 0 {
+		if _, err := kube.Kubectl("delete", strings.Join(resources, ","), "--selector", Label+"="+name, "--ignore-not-found"); err != nil {
+			fmt.Println("Error deleting cluster-scoped objects:", err)
+			return false
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(Dir, name)); err != nil {
+		fmt.Println("Error removing preview overlay:", err)
+		return false
+	}
+	fmt.Printf("Preview environment %s removed\n", name)
+	return true
+}
+
+// collectGarbage removes expired previews and returns false on failure
+func collectGarbage(dryRun bool) bool {
+	var namespaces []previewNamespace
+	if err := kube.List("namespaces", "", Label, &namespaces); err != nil {
+		fmt.Println("Error listing preview namespaces:", err)
+		return false
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREVIEW\tEXPIRES\tURL\tSTATUS")
+	var expired []string
+	for _, ns := range namespaces {
+		expiry := ns.Metadata.Annotations[expiresAtAnnotation]
+		url := ns.Metadata.Annotations[urlAnnotation]
+
+		status := "active"
+		expiresAt, err := time.Parse(time.RFC3339, expiry)
+		switch {
+		case err != nil:
+			status = "no expiry, kept"
+		case expiresAt.Before(now):
+			status = "expired"
+			expired = append(expired, ns.Metadata.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ns.Metadata.Name, expiry, url, status)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("%d expired preview environments would be removed\n", len(expired))
+		return true
+	}
+	ok := true
+	for _, name := range expired {
+		if !remove(name) {
+			ok = false
+		}
+	}
+	fmt.Printf("Removed %d expired preview environments\n", len(expired))
+	return ok
+}
+
+// postComment posts body on the pull request, returning false on failure
+func postComment(pr int, opts commentOptions, body string) bool {
+	if err := gitops.Comment(opts.remote, opts.provider, pr, commentMarker, body); err != nil {
+		fmt.Println("Error commenting on the pull request:", err)
+		return false
+	}
+	fmt.Printf("Posted the preview status on pull request %d\n", pr)
+	return true
+}
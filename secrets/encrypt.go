@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Dir returns the directory encrypted secrets of an engine are stored in
+func Dir(engine string) string {
+	return filepath.Join("secrets", engine)
+}
+
+// WriteEncrypted encrypts a plaintext Secret manifest with the engine and writes it to file.
+// The plaintext is never left on disk when encryption fails.
+func WriteEncrypted(engine, file string, plaintext []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	switch engine {
+	case "sops":
+		if _, err := exec.LookPath("sops"); err != nil {
+			return fmt.Errorf("SOPS is not installed, see https://github.com/mozilla/sops#installation")
+		}
+		// Encrypt in place so the creation rules in .sops.yaml match the file path
+		if err := os.WriteFile(file, plaintext, 0600); err != nil {
+			return err
+		}
+		var stderr bytes.Buffer
+		sopsCmd := exec.Command("sops", "--encrypt", "--in-place", "--encrypted-regex", "^(data|stringData)$", file)
+		sopsCmd.Stderr = &stderr
+		if err := sopsCmd.Run(); err != nil {
+			os.Remove(file)
+			return fmt.Errorf("sops --encrypt failed: %v: %s", err, stderr.String())
+		}
+		return os.Chmod(file, 0644)
+	case "sealed-secrets":
+		if _, err := exec.LookPath("kubeseal"); err != nil {
+			return fmt.Errorf("kubeseal is not installed, see https://github.com/bitnami-labs/sealed-secrets#installation")
+		}
+		var stdout, stderr bytes.Buffer
+		sealCmd := exec.Command("kubeseal", "--format", "yaml")
+		sealCmd.Stdin = bytes.NewReader(plaintext)
+		sealCmd.Stdout = &stdout
+		sealCmd.Stderr = &stderr
+		if err := sealCmd.Run(); err != nil {
+			return fmt.Errorf("kubeseal failed: %v: %s", err, stderr.String())
+		}
+		return os.WriteFile(file, stdout.Bytes(), 0644)
+	default:
+		return fmt.Errorf("unsupported secret management engine: %s", engine)
+	}
+}